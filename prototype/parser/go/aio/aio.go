@@ -0,0 +1,653 @@
+// Package aio fetches and queries AIO documents (ai-content.aio): it
+// discovers a site's document, verifies and decodes it, selects the chunks
+// matching a query and assembles them into a ContentEnvelope whose
+// Narrative fits an LLM context window.
+//
+// Parse and ParseWithOptions cover one-off calls. A Parser holds the HTTP
+// client, tokenizer, logger and Options shared by many parses.
+package aio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ContentEnvelope represents the standardized AIO output
+type ContentEnvelope struct {
+	ID        string  `json:"id"`
+	SourceURL string  `json:"source_url"`
+	Narrative string  `json:"narrative"`
+	Tokens    int     `json:"tokens"`
+	Items     []Chunk `json:"items,omitempty"`
+
+	// Groups maps each query term to the selected chunks it matched, in
+	// Items order (Options.GroupByKeyword)
+	Groups map[string][]Chunk `json:"groups,omitempty"`
+
+	// Unmatched holds the chunks the query did not match, in document
+	// order (Options.IncludeUnmatched). They are not in the narrative.
+	Unmatched []Chunk `json:"unmatched,omitempty"`
+
+	// Metadata is the document's title, description, license and other
+	// document-level metadata, for citing the content and checking its
+	// terms and freshness
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// Version is the aio_version the document declared and the one it was
+	// read as, after migration from an older layout; nil for scraped
+	// content
+	Version *VersionInfo `json:"version,omitempty"`
+
+	// Source is the strategy of Options.FallbackChain that produced the
+	// content, and DocumentURL the URL it was read from (the page itself,
+	// for scraped content). Source is empty for documents read through a
+	// SourceLoader, and both are empty for documents parsed from a reader
+	// or archive.
+	Source      SourceStrategy `json:"source,omitempty"`
+	DocumentURL string         `json:"document_url,omitempty"`
+
+	// Robots reports whether robots.txt allowed reading DocumentURL, and
+	// why; it is nil for documents not fetched over HTTP
+	Robots *RobotsReport `json:"robots,omitempty"`
+
+	// Spans maps each block of the narrative back to its chunks
+	Spans []Span `json:"spans,omitempty"`
+
+	// SignatureVerified is set when the document's signature was checked
+	// against Options.PublicKey and found valid
+	SignatureVerified bool `json:"signature_verified,omitempty"`
+
+	// Suggestions lists document keywords close to the query terms when
+	// the query matched nothing ("did you mean")
+	Suggestions []string `json:"suggestions,omitempty"`
+
+	// FailedPages lists paginated pages skipped under Options.PartialResult
+	FailedPages []PageError `json:"failed_pages,omitempty"`
+
+	// FailedDocuments lists the children of an index document skipped
+	// under Options.PartialResult
+	FailedDocuments []PageError `json:"failed_documents,omitempty"`
+
+	// TotalBytesLimitHit is set when pagination stopped at
+	// Options.MaxTotalBytes; the envelope holds the pages gathered so far
+	TotalBytesLimitHit bool `json:"total_bytes_limit_hit,omitempty"`
+
+	// HashMismatches lists selected chunks whose content failed hash
+	// verification
+	HashMismatches []HashMismatch `json:"hash_mismatches,omitempty"`
+
+	// Sanitized lists what Options.Sanitizer stripped from the selected
+	// chunks, and the chunks it rejected
+	Sanitized []SanitizeAction `json:"sanitized,omitempty"`
+
+	// Changes compares the whole document with the previous parse of it,
+	// under Options.TrackChanges
+	Changes *ChangeSet `json:"changes,omitempty"`
+
+	// IntegrityOK is set when no selected chunk failed hash verification.
+	// Chunks without a hash do not count against it; see Chunk.Verified
+	// for which ones were actually checked.
+	IntegrityOK bool `json:"integrity_ok"`
+
+	// DetectedLanguage is the document language guessed from its content
+	// under Options.DetectLanguage when the document declares none, and
+	// LanguageConfidence how sure the guess is, from 0 to 1
+	DetectedLanguage   string  `json:"detected_language,omitempty"`
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+
+	// Stats counts how the document's chunks were used
+	Stats *Stats `json:"stats,omitempty"`
+
+	// Raw holds the document bytes as fetched (the first page, for
+	// paginated documents) when Options.KeepRaw is set
+	Raw []byte `json:"-"`
+
+	// Timing records how long each phase of the parse took
+	Timing *Timing `json:"timing,omitempty"`
+}
+
+// Chunk represents a single content piece from .aio
+type Chunk struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Hash    string `json:"hash"`
+	Section string `json:"section,omitempty"`
+
+	// Format is how Content is written: "markdown", "plain" or
+	// "structured"
+	Format string `json:"format,omitempty"`
+
+	// ContentType is the kind of page the chunk comes from, e.g.
+	// "article", "product" or "faq", taken from its index entry when the
+	// chunk has none
+	ContentType string `json:"content_type,omitempty"`
+
+	// Category classifies the chunk, e.g. "pricing" or "faq", for
+	// intent-aware selection (Options.Intent)
+	Category string `json:"category,omitempty"`
+
+	// Language is the chunk's ISO 639-1 language when it differs from the
+	// document's. Under Options.DetectLanguage, chunks that declare none
+	// get a DetectedLanguage guessed from their content, with a
+	// LanguageConfidence from 0 to 1.
+	Language           string  `json:"language,omitempty"`
+	DetectedLanguage   string  `json:"detected_language,omitempty"`
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+
+	// Tags label the chunk's content type for structured retrieval
+	// (Options.Tags)
+	Tags []string `json:"tags,omitempty"`
+
+	// LineStart and LineEnd locate the chunk in the original page,
+	// 1-based and inclusive, for citations
+	LineStart int `json:"line_start,omitempty"`
+	LineEnd   int `json:"line_end,omitempty"`
+
+	// ByteStart and ByteEnd locate the chunk in the original page as byte
+	// offsets, 0-based and exclusive of ByteEnd
+	ByteStart int `json:"byte_start,omitempty"`
+	ByteEnd   int `json:"byte_end,omitempty"`
+
+	// Keywords are the chunk's index keywords, copied onto the chunk under
+	// Options.InlineKeywords
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Citation links to the chunk's lines in the source page, e.g.
+	// "https://example.com/pricing#L42-L67"; set during assembly when the
+	// chunk has line metadata
+	Citation string `json:"citation,omitempty"`
+
+	// Provenance records where a selected chunk can be cited and why it
+	// was selected; set during assembly
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Summarized is set when Options.Summarizer shortened the content to
+	// fit the token budget
+	Summarized bool `json:"summarized,omitempty"`
+
+	// Change is how the chunk differs from the previous parse of its
+	// document (Options.TrackChanges)
+	Change Change `json:"change,omitempty"`
+
+	// Updated is when the chunk content last changed (RFC 3339), and TTL
+	// how many seconds after that it should be considered current
+	Updated string `json:"updated,omitempty"`
+	TTL     int    `json:"ttl,omitempty"`
+
+	// HashAlgo names the algorithm of Hash when it has no "algo:" prefix;
+	// SHA-256 is assumed when both are absent
+	HashAlgo string `json:"hash_algo,omitempty"`
+
+	// Score is the query relevance of the chunk; zero when no query was given
+	Score float64 `json:"score,omitempty"`
+
+	// Tokens is what the chunk costs in the narrative, its separator
+	// included, as the budget ranks it against Options.MaxTokens. The
+	// envelope's Tokens counts the narrative whole, which rounding can put
+	// above the sum of its chunks'. Set on selected chunks only.
+	Tokens int `json:"tokens,omitempty"`
+
+	// Related marks a chunk that did not match the query but was pulled in
+	// for sharing keywords with the matches (Options.ExpandRelated)
+	Related bool `json:"related,omitempty"`
+
+	// Verified is set when the content matched Hash
+	Verified bool `json:"verified,omitempty"`
+
+	// Scraped marks a chunk taken from the HTML page rather than an AIO
+	// document (StrategyScrape or Options.MergeScraped)
+	Scraped bool `json:"scraped,omitempty"`
+
+	// SourceURL is the document the chunk was read from, set on the chunks
+	// of an index document and its children once they are merged
+	SourceURL string `json:"source_url,omitempty"`
+
+	// Embedding is the chunk's precomputed vector, made with the model
+	// named by AIOFile.Embeddings. It is read for retrieval and left out
+	// of selected chunks.
+	Embedding []float32 `json:"embedding,omitempty"`
+
+	// pos is the chunk's position in the document's content array
+	pos int
+
+	// matchedTerms are the query terms the chunk matched
+	matchedTerms []string
+
+	// lang is the language the chunk is matched in, under
+	// Options.Languages and Stemming
+	lang string
+}
+
+// AIOTag represents the JSON structure of .aio files
+type AIOFile struct {
+	Schema    string       `json:"$schema,omitempty"`
+	Version   string       `json:"aio_version"`
+	Generated string       `json:"generated,omitempty"` // RFC 3339
+	Language  string       `json:"language,omitempty"`
+	Metadata  *Metadata    `json:"metadata,omitempty"`
+	Signature *Signature   `json:"signature,omitempty"`
+	Index     []IndexEntry `json:"index"`
+	Content   []Chunk      `json:"content"`
+
+	// Next links to the following page of a paginated document
+	Next string `json:"next,omitempty"`
+
+	// Documents lists the child documents of an index document, whose
+	// own content may be empty
+	Documents []DocumentRef `json:"documents,omitempty"`
+
+	// Embeddings names the model of the chunks' precomputed Embedding
+	// vectors, for semantic retrieval (EmbeddingRetriever)
+	Embeddings *EmbeddingModel `json:"embeddings,omitempty"`
+
+	// dropped counts the chunks the streaming decoder left out of Content
+	// for not matching the query
+	dropped int
+
+	// version is how the decoder negotiated Version; nil for documents
+	// not decoded from JSON
+	version *VersionInfo
+}
+
+// IndexEntry is the retrieval metadata for one chunk
+type IndexEntry struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title,omitempty"`
+	Keywords []string `json:"keywords"`
+
+	// Path is the URL path of the page the chunk comes from, and Summary
+	// a sentence or two on what it covers
+	Path    string `json:"path,omitempty"`
+	Summary string `json:"summary,omitempty"`
+
+	// ContentType is the kind of page the chunk comes from: "article",
+	// "product", "faq", "documentation", "contact" or "legal"
+	ContentType string `json:"content_type,omitempty"`
+
+	// LastModified (RFC 3339) dates the chunk when it has no Updated field
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Parse attempts to fetch AIO content, falling back to basic scraping.
+// Where it looks is set by Options.FallbackChain; see DefaultFallbackChain.
+func Parse(url string, query string) (*ContentEnvelope, error) {
+	return defaultParser.Parse(url, query)
+}
+
+// ParseWithOptions is Parse with explicit selection and assembly options
+func ParseWithOptions(url string, query string, opts Options) (*ContentEnvelope, error) {
+	p := &Parser{Options: opts}
+	return p.Parse(url, query)
+}
+
+func parseAIO(r io.Reader, sourceURL string, query string, opts Options) (*ContentEnvelope, error) {
+	run := newParseRun(opts)
+	started := time.Now()
+	if opts.MaxBytes > 0 {
+		r = &limitedReader{r: r, n: opts.MaxBytes}
+	}
+	if streams(opts) {
+		aio, err := decodeStream(r, queryFilter(query, opts), opts)
+		run.timing.Decode = time.Since(started)
+		if err != nil {
+			return nil, err
+		}
+		run.report(PhaseDecode)
+		return assembleEnvelope(aio, sourceURL, query, opts, false, run)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	aio, verified, err := decodeAIO(data, opts)
+	run.timing.Decode = time.Since(started)
+	if err != nil {
+		return nil, err
+	}
+	run.report(PhaseDecode)
+	env, err := assembleEnvelope(aio, sourceURL, query, opts, verified, run)
+	if err != nil {
+		return nil, err
+	}
+	if opts.KeepRaw {
+		env.Raw = data
+	}
+	return env, nil
+}
+
+// decodeAIO decodes one .aio document, unpacking it when compressed or
+// CBOR and migrating it from an older layout, applies the chunk limits of
+// opts and checks its signature, and under Options.StrictHashes its chunk
+// hashes, reporting whether the signature was verified
+func decodeAIO(data []byte, opts Options) (*AIOFile, bool, error) {
+	data, err := unpack(data, opts.MaxBytes)
+	if err != nil {
+		return nil, false, err
+	}
+	data = trimLeadingNoise(data)
+	normalized, version, err := normalizeVersion(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := checkShape(normalized); err != nil {
+		return nil, false, err
+	}
+	aio := AIOFile{version: version}
+	if err := json.Unmarshal(normalized, &aio); err != nil {
+		return nil, false, shapeError(err)
+	}
+	if err := checkLimits(aio.Content, opts); err != nil {
+		return nil, false, err
+	}
+
+	verified, err := checkSignature(data, aio.Signature, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	if opts.StrictHashes {
+		if err := checkDocumentHashes(aio.Content); err != nil {
+			return nil, false, err
+		}
+	}
+	return &aio, verified, nil
+}
+
+// utf8BOM is the byte order mark some editors prepend to UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// trimLeadingNoise drops byte order marks and whitespace ahead of the JSON
+// document, which encoding/json rejects as invalid characters
+func trimLeadingNoise(data []byte) []byte {
+	for {
+		trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+		if len(trimmed) == len(data) {
+			return data
+		}
+		data = trimmed
+	}
+}
+
+// queryTerms parses query into the terms chunks are matched against
+func queryTerms(query string, opts Options) []queryTerm {
+	terms := parseTerms(query)
+	if opts.RemoveStopwords {
+		stopwords := opts.Stopwords
+		if stopwords == nil {
+			stopwords = DefaultStopwords
+		}
+		terms = removeStopwords(terms, stopwords)
+	}
+	return terms
+}
+
+// queryTermsIn parses query into the terms chunks in lang are matched
+// against, lower-cased by the language's rules and, under
+// Options.Stemming, stemmed
+func queryTermsIn(query string, opts Options, lang string) []queryTerm {
+	terms := queryTerms(lowerFor(lang)(query), opts)
+	if opts.Stemming {
+		for i, t := range terms {
+			if !t.glob {
+				terms[i].stem = stem(t.text, lang)
+			}
+		}
+	}
+	return terms
+}
+
+// assembleEnvelope selects the chunks of a decoded document matching query
+// and assembles them into an envelope
+func assembleEnvelope(aio *AIOFile, sourceURL string, query string, opts Options, verified bool, run *parseRun) (*ContentEnvelope, error) {
+	timing := run.timing
+	started := time.Now()
+	var selectedChunks, unmatched []Chunk
+
+	// Targeted retrieval logic
+	terms := queryTerms(query, opts)
+	entries := indexByID(aio.Index)
+	keywords := keywordsByID(aio.Index)
+	weights := opts.FieldWeights.orDefault()
+	minTerms := min(max(opts.MinMatchTerms, 1), len(terms))
+	stats := &Stats{TotalChunks: len(aio.Content) + aio.dropped}
+	now := time.Now()
+	var docUpdated string
+	if aio.Metadata != nil {
+		docUpdated = aio.Metadata.LastUpdated
+	}
+
+	// A detected document language only picks the estimator when the
+	// detection is fairly sure of it
+	lang := documentLanguage(aio)
+	var detected string
+	var confidence float64
+	if opts.DetectLanguage && lang == "" {
+		detected, confidence = detectLanguage(languageSample(aio.Content))
+		if confidence >= minDetectConfidence {
+			lang = detected
+		}
+	}
+	// Each chunk is matched by the rules of its own language, which is
+	// only worked out for the options that need it
+	var langs []chunkLanguage
+	termsIn := map[string][]queryTerm{}
+	if len(opts.Languages) > 0 || opts.Stemming {
+		langs = chunkLanguages(aio.Content, lang, opts.DetectLanguage)
+		terms = queryTermsIn(query, opts, lang)
+		termsIn[lang] = terms
+	}
+
+	// A Retriever scores the whole content up front; without embeddings
+	// for the document it leaves ranking to the keyword models
+	var retrieved []float64
+	if opts.Retriever != nil && strings.TrimSpace(query) != "" {
+		var err error
+		retrieved, err = opts.Retriever.Score(run.ctx, query, aio)
+		switch {
+		case errors.Is(err, ErrNoEmbeddings):
+			retrieved = nil
+			stats.KeywordFallback = true
+		case err != nil:
+			return nil, fmt.Errorf("retrieving: %w", err)
+		case len(retrieved) != len(aio.Content):
+			return nil, fmt.Errorf("retrieving: got %d scores for %d chunks", len(retrieved), len(aio.Content))
+		}
+	}
+	var bm25 *bm25Index
+	if opts.Ranking == RankBM25 && len(terms) > 0 && retrieved == nil {
+		bm25 = newBM25(aio.Content, langs, entries, terms, weights)
+	}
+
+	for i, chunk := range aio.Content {
+		chunk.pos = i
+		run.progress.ChunksProcessed++
+		run.report(PhaseChunk)
+		if opts.Filter != nil && !opts.Filter(chunk) {
+			continue
+		}
+		if len(opts.Tags) > 0 && !hasTags(chunk, opts.Tags, opts.MatchAllTags) {
+			continue
+		}
+		if opts.MaxAge > 0 && isStale(chunk, entries[chunk.ID], docUpdated, opts.MaxAge, now) {
+			stats.StaleSkipped++
+			continue
+		}
+		chunkTerms := terms
+		if langs != nil {
+			l := langs[i]
+			chunk.lang = l.lang
+			if chunk.Language == "" && l.detected != "" {
+				chunk.DetectedLanguage, chunk.LanguageConfidence = l.detected, l.confidence
+			}
+			if len(opts.Languages) > 0 && !inLanguages(l.lang, opts.Languages) {
+				stats.LanguageSkipped++
+				continue
+			}
+			if chunkTerms = termsIn[l.lang]; chunkTerms == nil {
+				chunkTerms = queryTermsIn(query, opts, l.lang)
+				termsIn[l.lang] = chunkTerms
+			}
+		}
+		if len(terms) == 0 && retrieved == nil {
+			selectedChunks = append(selectedChunks, chunk)
+			continue
+		}
+		var score float64
+		var matched []string
+		switch {
+		case retrieved != nil:
+			score = retrieved[i]
+		case bm25 != nil:
+			score, matched = bm25.score(i)
+		default:
+			score, matched = scoreChunk(chunk, entries[chunk.ID], keywords[chunk.ID], chunkTerms, weights)
+		}
+		score *= opts.Intent.boost(chunk.Category)
+		chunk.Score = score
+		chunk.matchedTerms = matched
+		if score > 0 && score >= opts.MinScore && (retrieved != nil || len(matched) >= minTerms) {
+			selectedChunks = append(selectedChunks, chunk)
+		} else {
+			unmatched = append(unmatched, chunk)
+		}
+	}
+	rankChunks(selectedChunks, opts.TieBreak)
+	if opts.ExpandRelated > 0 && len(selectedChunks) > 0 {
+		related := relatedChunks(selectedChunks, unmatched, keywords, opts.ExpandRelated)
+		selectedChunks = append(selectedChunks, related...)
+	}
+	stats.Available = len(selectedChunks)
+	selectedChunks = window(selectedChunks, opts.Offset, opts.Count)
+	timing.Selection = time.Since(started)
+	run.report(PhaseSelection)
+	started = time.Now()
+
+	var suggestions []string
+	if stats.Available == 0 && len(terms) > 0 {
+		suggestions = suggestKeywords(aio, plainTerms(terms))
+	}
+
+	// Hashes cover the content as published, so check them before any
+	// reference expansion or transform rewrites it
+	mismatches, err := verifyHashes(selectedChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedChunks, err = resolveRefs(selectedChunks, aio.Content, opts.StrictRefs, opts.MaxChunkBytes)
+	if err != nil {
+		return nil, err
+	}
+	selectedChunks, sanitized := sanitizeChunks(selectedChunks, opts.Sanitizer)
+	for i := range selectedChunks {
+		selectedChunks[i].Citation = citation(sourceURL, selectedChunks[i])
+		selectedChunks[i].Embedding = nil
+		entry := entries[selectedChunks[i].ID]
+		selectedChunks[i].Provenance = provenance(sourceURL, selectedChunks[i], entry, terms)
+		if opts.InlineKeywords && entry != nil {
+			selectedChunks[i].Keywords = append([]string(nil), entry.Keywords...)
+		}
+		if c := &selectedChunks[i]; c.ContentType == "" && entry != nil {
+			c.ContentType = entry.ContentType
+		}
+		if c := &selectedChunks[i]; opts.DetectLanguage && c.Language == "" && c.DetectedLanguage == "" {
+			c.DetectedLanguage, c.LanguageConfidence = detectLanguage(c.Content)
+		}
+	}
+	selectedChunks = applyTransforms(selectedChunks, opts.Transforms)
+
+	estimate := estimatorFor(lang)
+	if opts.CharsPerToken > 0 {
+		estimate = charsPerTokenEstimator(opts.CharsPerToken)
+	}
+	if run.tokenizer != nil {
+		estimate = run.tokenizer
+	}
+
+	stats.Matched = len(selectedChunks)
+	selectedChunks, stats.EmptySkipped = dropEmptyChunks(selectedChunks)
+
+	// The narrative is built from whole chunks, or in sentence mode from
+	// the best sentences of the matched chunks
+	units := selectedChunks
+	tokens := 0
+	if opts.TopSentences > 0 {
+		units = topSentences(selectedChunks, terms, opts.TopSentences, lang)
+		candidates := len(units)
+		units, tokens = applyBudget(units, opts, estimate)
+		stats.BudgetDropped = candidates - len(units)
+		selectedChunks = sourceChunks(selectedChunks, units)
+	} else {
+		candidates := len(selectedChunks)
+		selectedChunks, stats.Summarized = summarizeChunks(selectedChunks, query, opts, estimate, run)
+		selectedChunks, tokens = applyBudget(selectedChunks, opts, estimate)
+		stats.BudgetDropped = candidates - len(selectedChunks)
+		units = selectedChunks
+	}
+
+	// The budget has already counted the narrative of whole blocks, so it
+	// needs no second pass. In items-only mode the caller renders the
+	// chunks itself and the count is what they would cost. Merged blocks
+	// lose their separators and are recounted.
+	var narrative string
+	var spans []Span
+	if !opts.ItemsOnly {
+		merge := opts.MergeAdjacent && opts.TopSentences == 0
+		narrative, spans = buildNarrative(units, merge)
+		if merge && len(spans) < len(units) {
+			tokens = estimate(narrative)
+		}
+		if opts.TruncationNotice && stats.BudgetDropped > 0 {
+			// applyBudget kept room for the notice; a budget too small for
+			// the notice alone goes without
+			full := narrative + truncationNotice(stats.BudgetDropped, opts.TopSentences > 0) + chunkSeparator
+			if n := estimate(full); opts.MaxTokens <= 0 || n <= opts.MaxTokens {
+				narrative, tokens = full, n
+			}
+		}
+	}
+
+	env := &ContentEnvelope{
+		ID:        envelopeID(sourceURL, selectedChunks),
+		SourceURL: sourceURL,
+		Narrative: narrative,
+		Tokens:    tokens,
+		Items:     selectedChunks,
+		Spans:     spans,
+
+		SignatureVerified: verified,
+		Suggestions:       suggestions,
+		HashMismatches:    mismatches,
+		Sanitized:         sanitized,
+		IntegrityOK:       len(mismatches) == 0,
+		Stats:             stats,
+		Timing:            timing,
+		Metadata:          envelopeMetadata(aio),
+		Version:           aio.version,
+
+		DetectedLanguage:   detected,
+		LanguageConfidence: confidence,
+	}
+	if opts.GroupByKeyword {
+		env.Groups = groupByKeyword(selectedChunks)
+	}
+	if opts.IncludeUnmatched {
+		env.Unmatched = unmatched
+	}
+	if !opts.ItemsOnly {
+		if err := frameNarrative(env, opts); err != nil {
+			return nil, err
+		}
+		if opts.HeaderTemplate != "" || opts.FooterTemplate != "" {
+			env.Tokens = estimate(env.Narrative)
+		}
+	}
+	timing.Assembly = time.Since(started)
+	run.report(PhaseDone)
+	return env, nil
+}
@@ -0,0 +1,64 @@
+package aio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustEncode encodes doc as a document's JSON, failing the test on error
+func mustEncode(t testing.TB, doc *AIOFile) []byte {
+	t.Helper()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// scalingDocument returns a document of n chunks, each with an index
+// entry, for benchmarks that grow with the document
+func scalingDocument(tb testing.TB, n int) []byte {
+	doc := AIOFile{Version: "2.1"}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("chunk-%d", i)
+		doc.Index = append(doc.Index, IndexEntry{ID: id, Title: fmt.Sprintf("Section %d", i), Keywords: []string{fmt.Sprintf("topic%d", i%50), "pricing", "plans"}})
+		doc.Content = append(doc.Content, Chunk{ID: id, Content: strings.Repeat(fmt.Sprintf("Sentence %d about pricing and topic%d. ", i, i%50), 5)})
+	}
+	return mustEncode(tb, &doc)
+}
+
+// BenchmarkParseTiming reports what each phase of Timing adds up to per
+// parse, beside the cost of the clock reads that measure them
+func BenchmarkParseTiming(b *testing.B) {
+	data := scalingDocument(b, 200)
+	b.Run("parse", func(b *testing.B) {
+		var sum Timing
+		for i := 0; i < b.N; i++ {
+			env, err := parseAIO(bytes.NewReader(data), "", "pricing topic7", Options{MaxTokens: 1000})
+			if err != nil {
+				b.Fatal(err)
+			}
+			sum.Decode += env.Timing.Decode
+			sum.Selection += env.Timing.Selection
+			sum.Assembly += env.Timing.Assembly
+		}
+		n := float64(b.N)
+		b.ReportMetric(float64(sum.Decode.Nanoseconds())/n, "decode-ns/op")
+		b.ReportMetric(float64(sum.Selection.Nanoseconds())/n, "selection-ns/op")
+		b.ReportMetric(float64(sum.Assembly.Nanoseconds())/n, "assembly-ns/op")
+	})
+	// Each phase costs one time.Now and one time.Since
+	b.Run("clock", func(b *testing.B) {
+		var d time.Duration
+		for i := 0; i < b.N; i++ {
+			d += time.Since(time.Now())
+		}
+		if d < 0 {
+			b.Fatal(d)
+		}
+	})
+}
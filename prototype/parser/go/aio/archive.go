@@ -0,0 +1,95 @@
+package aio
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ParseArchive parses every .aio entry of a zip or (optionally gzipped) tar
+// archive, returning one envelope per entry with SourceURL set to the
+// entry name. Other entries are skipped. Entries that fail to parse are
+// reported together in the returned error, alongside the envelopes of the
+// entries that succeeded.
+func ParseArchive(r io.Reader, query string) ([]*ContentEnvelope, error) {
+	return ParseArchiveWithOptions(r, query, Options{})
+}
+
+// ParseArchiveWithOptions is ParseArchive with explicit options
+func ParseArchiveWithOptions(r io.Reader, query string, opts Options) ([]*ContentEnvelope, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var envs []*ContentEnvelope
+	var errs []error
+	visit := func(name string, body io.Reader) {
+		if !strings.EqualFold(path.Ext(name), ".aio") {
+			return
+		}
+		env, err := parseAIO(body, name, query, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		envs = append(envs, env)
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		err = walkZip(data, visit)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			err = walkTar(gz, visit)
+		}
+	default:
+		err = walkTar(bytes.NewReader(data), visit)
+	}
+	if err != nil {
+		return envs, err
+	}
+	return envs, errors.Join(errs...)
+}
+
+func walkZip(data []byte, visit func(string, io.Reader)) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		visit(f.Name, rc)
+		rc.Close()
+	}
+	return nil
+}
+
+func walkTar(r io.Reader, visit func(string, io.Reader)) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			visit(hdr.Name, tr)
+		}
+	}
+}
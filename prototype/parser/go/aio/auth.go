@@ -0,0 +1,200 @@
+package aio
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Credential authenticates the requests to one host (Options.Credentials)
+// with one of: a static bearer token, a refreshable one from TokenSource,
+// an API key in a custom header, or basic auth
+type Credential struct {
+	BearerToken string
+
+	// TokenSource supplies a bearer token for each request, so it can
+	// refresh one that has expired, e.g. an OAuth access token
+	TokenSource TokenSource
+
+	// Header and Value send an API key, e.g. "X-API-Key"
+	Header string
+	Value  string
+
+	Username string
+	Password string
+}
+
+// TokenSource returns the bearer token to send with a request. Token is
+// called for every request to its host, from concurrent parses, so it
+// should cache the token until it nears expiry.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// ErrUnauthorized matches, through errors.Is, the AuthError of a request
+// refused with 401 Unauthorized or 403 Forbidden
+var ErrUnauthorized = errors.New("aio: unauthorized")
+
+// AuthError reports a request the server refused for want of valid
+// credentials, so an agent can ask its user for them and retry with
+// Options.Credentials
+type AuthError struct {
+	URL        string
+	StatusCode int
+
+	// Challenge is the WWW-Authenticate header of a 401, naming the
+	// scheme and realm the server expects, e.g. `Bearer realm="api"`
+	Challenge string
+}
+
+func (e *AuthError) Error() string {
+	msg := fmt.Sprintf("GET %s: %d %s: %v", e.URL, e.StatusCode, http.StatusText(e.StatusCode), ErrUnauthorized)
+	if e.Challenge != "" {
+		msg += " (" + e.Challenge + ")"
+	}
+	return msg
+}
+
+func (e *AuthError) Is(target error) bool { return target == ErrUnauthorized }
+
+// statusError is the error for a response to url that is not 200 OK: an
+// AuthError for 401 and 403, and otherwise one naming the status
+func statusError(url string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &AuthError{URL: url, StatusCode: resp.StatusCode, Challenge: resp.Header.Get("WWW-Authenticate")}
+	}
+	return fmt.Errorf("GET %s: %s", url, resp.Status)
+}
+
+// credentialFor finds the credential for host, which may carry a port:
+// one keyed by the host and port, else by the host alone, else by a
+// wildcard "*.example.com" covering its subdomains
+func credentialFor(creds map[string]Credential, host string) (Credential, bool) {
+	if len(creds) == 0 {
+		return Credential{}, false
+	}
+	host = strings.ToLower(host)
+	if c, ok := creds[host]; ok {
+		return c, true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if c, ok := creds[host]; ok {
+			return c, true
+		}
+	}
+	for domain := host; ; {
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return Credential{}, false
+		}
+		if c, ok := creds["*."+parent]; ok {
+			return c, true
+		}
+		domain = parent
+	}
+}
+
+// authorize sets the Authorization of req: the credential for its host,
+// if there is one, over any the Parse call's headers carry, and otherwise
+// the session's origin authorization when req goes to the parsed URL's
+// host. Other hosts, reached by next links, sitemaps, mirrors or child
+// documents, are never sent it.
+func (s *session) authorize(req *http.Request) error {
+	c, ok := credentialFor(s.opts.Credentials, req.URL.Host)
+	if !ok {
+		if s.originAuth != "" && strings.EqualFold(req.URL.Host, s.originHost) {
+			req.Header.Set("Authorization", s.originAuth)
+		}
+		return nil
+	}
+	req.Header.Del("Authorization")
+	switch {
+	case c.TokenSource != nil:
+		token, err := c.TokenSource.Token(s.ctx)
+		if err != nil {
+			return fmt.Errorf("GET %s: token for %s: %w", req.URL, req.URL.Host, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	case c.Header != "":
+		req.Header.Set(c.Header, c.Value)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return nil
+}
+
+// stripCredentials removes any userinfo from rawURL, returning the clean
+// URL and the Authorization value the userinfo translates to (empty when
+// there was none or rawURL does not parse)
+func stripCredentials(rawURL string) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL, ""
+	}
+
+	password, _ := u.User.Password()
+	creds := u.User.Username() + ":" + password
+	u.User = nil
+	return u.String(), "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+}
+
+// hostPort is the host of rawURL with its port, if it names one
+func hostPort(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// requestHeader builds the headers sent with every request of a Parse
+// call. Explicit opts.Headers win over opts.UserAgent.
+func requestHeader(opts Options) http.Header {
+	header := opts.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if header.Get("User-Agent") == "" {
+		header.Set("User-Agent", userAgent(opts))
+	}
+	if opts.PreferCBOR && header.Get("Accept") == "" {
+		header.Set("Accept", CBORContentType+", "+ContentType+";q=0.9, */*;q=0.8")
+	}
+	return header
+}
+
+// originAuthorization is the Authorization sent to the host of the parsed
+// URL only: opts.BearerToken, else the credentials embedded in the URL.
+// An Authorization in opts.Headers, sent everywhere, takes precedence.
+func originAuthorization(opts Options, urlAuth string) string {
+	switch {
+	case opts.Headers.Get("Authorization") != "":
+		return ""
+	case opts.BearerToken != "":
+		return "Bearer " + opts.BearerToken
+	}
+	return urlAuth
+}
+
+// userAgent is Options.UserAgent, or DefaultUserAgent when it is empty
+func userAgent(opts Options) string {
+	if opts.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return opts.UserAgent
+}
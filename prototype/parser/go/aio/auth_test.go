@@ -0,0 +1,138 @@
+package aio
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// authRecorder serves an AIO document and records the Authorization of
+// every request to it by path
+type authRecorder struct {
+	*httptest.Server
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+func newAuthRecorder(t *testing.T, doc func() string) *authRecorder {
+	r := &authRecorder{seen: map[string]string{}}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.seen[req.URL.Path] = req.Header.Get("Authorization")
+		r.mu.Unlock()
+		if req.URL.Path != "/ai-content.aio" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		w.Write([]byte(doc()))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *authRecorder) auth(path string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.seen[path]
+	return v, ok
+}
+
+func TestOriginAuthorizationStaysOnOrigin(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+	tests := []struct {
+		name       string
+		userinfo   string
+		opts       Options
+		wantOrigin string
+		wantOther  string
+	}{
+		{name: "userinfo", userinfo: "alice:secret@", wantOrigin: basic},
+		{name: "bearer token", opts: Options{BearerToken: "tok"}, wantOrigin: "Bearer tok"},
+		{name: "bearer token over userinfo", userinfo: "alice:secret@", opts: Options{BearerToken: "tok"}, wantOrigin: "Bearer tok"},
+		{
+			name:       "explicit header goes everywhere",
+			opts:       Options{Headers: http.Header{"Authorization": {"Custom x"}}, BearerToken: "tok"},
+			wantOrigin: "Custom x",
+			wantOther:  "Custom x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := newAuthRecorder(t, func() string {
+				return `{"aio_version": "2.1", "content": [{"id": "two", "content": "Second page."}]}`
+			})
+			origin := newAuthRecorder(t, func() string {
+				return `{"aio_version": "2.1", "next": "` + other.URL + `/ai-content.aio", "content": [{"id": "one", "content": "First page."}]}`
+			})
+			opts := tt.opts
+			opts.AllowPrivateNetworks = true
+			opts.IgnoreRobots = true
+			opts.FollowNext = true
+			u := strings.Replace(origin.URL, "http://", "http://"+tt.userinfo, 1) + "/"
+			env, err := ParseWithOptions(u, "", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(env.Items) != 2 {
+				t.Fatalf("got %d chunks, want both pages", len(env.Items))
+			}
+			if got, _ := origin.auth("/ai-content.aio"); got != tt.wantOrigin {
+				t.Errorf("origin got Authorization %q, want %q", got, tt.wantOrigin)
+			}
+			got, ok := other.auth("/ai-content.aio")
+			if !ok {
+				t.Fatal("the next page was not fetched")
+			}
+			if got != tt.wantOther {
+				t.Errorf("other host got Authorization %q, want %q", got, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestCredentialFor(t *testing.T) {
+	creds := map[string]Credential{
+		"api.example.com:8443": {BearerToken: "port"},
+		"api.example.com":      {BearerToken: "host"},
+		"*.example.org":        {BearerToken: "wild"},
+	}
+	tests := []struct {
+		host string
+		want string
+		ok   bool
+	}{
+		{"api.example.com:8443", "port", true},
+		{"api.example.com:9000", "host", true},
+		{"API.example.com", "host", true},
+		{"a.b.example.org", "wild", true},
+		{"example.org", "", false},
+		{"other.com", "", false},
+	}
+	for _, tt := range tests {
+		c, ok := credentialFor(creds, tt.host)
+		if ok != tt.ok || c.BearerToken != tt.want {
+			t.Errorf("credentialFor(%q) = %q, %v; want %q, %v", tt.host, c.BearerToken, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	_, err := ParseWithOptions(srv.URL+"/", "", Options{AllowPrivateNetworks: true, IgnoreRobots: true, FallbackChain: []SourceStrategy{StrategyDirectURL}})
+	var ae *AuthError
+	if !errors.Is(err, ErrUnauthorized) || !errors.As(err, &ae) {
+		t.Fatalf("err = %v, want an AuthError", err)
+	}
+	if ae.Challenge != `Bearer realm="api"` {
+		t.Errorf("Challenge = %q", ae.Challenge)
+	}
+}
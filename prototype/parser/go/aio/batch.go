@@ -0,0 +1,162 @@
+package aio
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of sites ParseMany fetches at once when
+// Options.Concurrency is not set
+const DefaultConcurrency = 4
+
+// ParseResult is the outcome of parsing one site in ParseMany
+type ParseResult struct {
+	// Index is the position of URL in the urls passed to ParseMany
+	Index    int
+	URL      string
+	Envelope *ContentEnvelope
+	Err      error
+}
+
+// ParseMany parses each of urls with the same query and options; see
+// Parser.ParseMany
+func ParseMany(ctx context.Context, urls []string, query string, opts Options) []ParseResult {
+	p := &Parser{Options: opts}
+	return p.ParseMany(ctx, urls, query)
+}
+
+// ParseMany parses each URL with the same query, fetching up to
+// Options.Concurrency sites at once, and no more than
+// Options.HostConcurrency from one host. Results are returned in the
+// order of urls, and a failure on one site does not stop the others. URLs
+// that normalize to the same address are parsed once and share the result.
+func (p *Parser) ParseMany(ctx context.Context, urls []string, query string) []ParseResult {
+	results := make([]ParseResult, len(urls))
+	for r := range p.ParseManyStream(ctx, urls, query) {
+		results[r.Index] = r
+	}
+	return results
+}
+
+// ParseManyStream is ParseMany delivering each result as soon as its site
+// is parsed, so callers can process early finishers while slow sites are
+// still loading. The channel is closed after the last result; callers
+// must drain it.
+func (p *Parser) ParseManyStream(ctx context.Context, urls []string, query string) <-chan ParseResult {
+	workers := p.Options.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	out := make(chan ParseResult)
+	sem := make(chan struct{}, workers)
+	hosts := newHostGate(p.Options.HostConcurrency, p.Options.PolitenessDelay)
+
+	// Duplicates are answered together with the first URL of their kind
+	dups := map[string][]int{} // normalized URL -> indices sharing it
+	var order []string
+	for i, u := range urls {
+		key := normalizeURL(u)
+		if _, ok := dups[key]; !ok {
+			order = append(order, key)
+		}
+		dups[key] = append(dups[key], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range order {
+		wg.Add(1)
+		go func(indices []int) {
+			defer wg.Done()
+			first := urls[indices[0]]
+			var env *ContentEnvelope
+			err := hosts.do(ctx, first, func() error {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				var err error
+				env, err = p.ParseContext(ctx, first, query)
+				return err
+			})
+			for _, i := range indices {
+				out <- ParseResult{Index: i, URL: urls[i], Envelope: env, Err: err}
+			}
+		}(dups[key])
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// hostGate limits the parses running against each host and spaces out
+// their starts
+type hostGate struct {
+	limit int
+	delay time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	sem  chan struct{} // nil without a limit
+	next time.Time     // earliest start of the next parse
+}
+
+func newHostGate(limit int, delay time.Duration) *hostGate {
+	return &hostGate{limit: limit, delay: delay, hosts: map[string]*hostState{}}
+}
+
+// do runs parse once the host of rawURL has a free slot and its
+// politeness delay has passed, or returns the context's error if it ends
+// first
+func (g *hostGate) do(ctx context.Context, rawURL string, parse func() error) error {
+	if g.limit <= 0 && g.delay <= 0 {
+		return parse()
+	}
+	host := rawURL
+	if u, err := url.Parse(normalizeURL(rawURL)); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	g.mu.Lock()
+	h := g.hosts[host]
+	if h == nil {
+		h = &hostState{}
+		if g.limit > 0 {
+			h.sem = make(chan struct{}, g.limit)
+		}
+		g.hosts[host] = h
+	}
+	g.mu.Unlock()
+
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+			defer func() { <-h.sem }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if g.delay > 0 {
+		g.mu.Lock()
+		start := time.Now()
+		if h.next.After(start) {
+			start = h.next
+		}
+		h.next = start.Add(g.delay)
+		g.mu.Unlock()
+		if wait := time.Until(start); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+	return parse()
+}
@@ -0,0 +1,121 @@
+package aio
+
+import (
+	"math"
+	"strings"
+)
+
+// Ranking selects how chunks are scored against a query
+type Ranking int
+
+const (
+	// RankFields, the default, sums FieldWeights for each field a query
+	// term occurs in. It suits small, well-indexed documents, where the
+	// author's keywords say best what a chunk is about.
+	RankFields Ranking = iota
+
+	// RankBM25 scores with Okapi BM25 over each chunk's content, index
+	// title and keywords, so rare terms and repeated mentions count for
+	// more, and long chunks do not win by size alone. Field matches are
+	// weighted by FieldWeights, each occurrence in the title counting
+	// Title times, and so on.
+	RankBM25
+)
+
+// BM25 parameters: k1 saturates repeated terms, b normalizes by length
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25Index holds the corpus statistics BM25 scores a query against
+type bm25Index struct {
+	freqs  []map[string]float64 // weighted term frequencies per chunk
+	lens   []float64            // weighted token count per chunk
+	avgLen float64
+	idf    []float64 // per query term
+	terms  []queryTerm
+}
+
+// newBM25 gathers term statistics over all of content for terms. A
+// query term matches a word the way it matches a field in RankFields:
+// as a substring, or as a wildcard pattern. Each chunk is lower-cased by
+// the rules of its language in langs, when there are languages.
+func newBM25(content []Chunk, langs []chunkLanguage, entries map[string]*IndexEntry, terms []queryTerm, w FieldWeights) *bm25Index {
+	ix := &bm25Index{
+		freqs: make([]map[string]float64, len(content)),
+		lens:  make([]float64, len(content)),
+		idf:   make([]float64, len(terms)),
+		terms: terms,
+	}
+	total := 0.0
+	for i, c := range content {
+		freq := map[string]float64{}
+		lower := strings.ToLower
+		if langs != nil {
+			lower = lowerFor(langs[i].lang)
+		}
+		add := func(text string, weight float64) {
+			for _, word := range strings.FieldsFunc(lower(text), isWordSeparator) {
+				freq[word] += weight
+				ix.lens[i] += weight
+			}
+		}
+		add(c.Content, w.Content)
+		if e := entries[c.ID]; e != nil {
+			add(e.Title, w.Title)
+			for _, k := range e.Keywords {
+				add(k, w.Keywords)
+			}
+		}
+		ix.freqs[i] = freq
+		total += ix.lens[i]
+	}
+	if len(content) > 0 {
+		ix.avgLen = total / float64(len(content))
+	}
+
+	n := float64(len(content))
+	for t, term := range terms {
+		df := 0.0
+		for _, freq := range ix.freqs {
+			if termFreq(freq, term) > 0 {
+				df++
+			}
+		}
+		ix.idf[t] = math.Log(1 + (n-df+0.5)/(df+0.5))
+	}
+	return ix
+}
+
+// score rates the chunk at document position pos, returning the terms
+// it matched
+func (ix *bm25Index) score(pos int) (float64, []string) {
+	freq, length := ix.freqs[pos], ix.lens[pos]
+	norm := 1.0
+	if ix.avgLen > 0 {
+		norm = 1 - bm25B + bm25B*length/ix.avgLen
+	}
+	score := 0.0
+	var matched []string
+	for t, term := range ix.terms {
+		tf := termFreq(freq, term)
+		if tf == 0 {
+			continue
+		}
+		matched = append(matched, term.text)
+		score += ix.idf[t] * tf * (bm25K1 + 1) / (tf + bm25K1*norm) * term.boost
+	}
+	return score, matched
+}
+
+// termFreq sums the frequencies of the words term matches
+func termFreq(freq map[string]float64, term queryTerm) float64 {
+	tf := 0.0
+	for word, f := range freq {
+		if term.matches(word) {
+			tf += f
+		}
+	}
+	return tf
+}
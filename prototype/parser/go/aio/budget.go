@@ -0,0 +1,157 @@
+package aio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// chunkSeparator is written after every chunk in the narrative
+const chunkSeparator = "\n\n"
+
+// chunkTokens is the cost of a chunk once assembled into the narrative
+func chunkTokens(c Chunk, estimate tokenEstimator) int {
+	return estimate(c.Content + chunkSeparator)
+}
+
+// applyBudget drops chunks that do not fit within opts.MaxTokens,
+// preserving the order of the chunks it keeps and setting each one's
+// Tokens. It returns the kept chunks with the token count of their
+// narrative of whole blocks, which is what the budget was charged. When
+// chunks are dropped and the narrative ends with a truncation notice,
+// room for the notice is kept out of the budget.
+func applyBudget(chunks []Chunk, opts Options, estimate tokenEstimator) ([]Chunk, int) {
+	costs := make([]int, len(chunks))
+	total := 0
+	for i := range chunks {
+		costs[i] = chunkTokens(chunks[i], estimate)
+		chunks[i].Tokens = costs[i]
+		total += costs[i]
+	}
+	kept, tokens := fitBudget(chunks, costs, total, opts, estimate, "")
+	if opts.MaxTokens > 0 && len(kept) < len(chunks) && opts.TruncationNotice && !opts.ItemsOnly {
+		// No notice names more chunks than there are, so this one is at
+		// least as long as the one written
+		reserve := truncationNotice(len(chunks), opts.TopSentences > 0) + chunkSeparator
+		kept, tokens = fitBudget(chunks, costs, total, opts, estimate, reserve)
+	}
+	return kept, tokens
+}
+
+// fitBudget is applyBudget leaving room for reserve after the narrative.
+// Chunks are picked by their own costs, then the narrative they make is
+// counted whole: estimates that round down per chunk can sum to less than
+// the whole costs, so when that is over budget the lowest ranked are
+// dropped until it fits, the most that do being found by bisection.
+func fitBudget(chunks []Chunk, costs []int, total int, opts Options, estimate tokenEstimator, reserve string) ([]Chunk, int) {
+	budget := opts.MaxTokens
+	if reserve != "" {
+		budget = max(budget-estimate(reserve), 0)
+	}
+	kept := pickChunks(chunks, costs, total, budget, opts)
+	if opts.MaxTokens <= 0 || estimate(joinBlocks(kept)+reserve) <= opts.MaxTokens {
+		return kept, estimate(joinBlocks(kept))
+	}
+	n := sort.Search(len(kept), func(n int) bool {
+		return estimate(joinBlocks(kept[:n+1])+reserve) > opts.MaxTokens
+	})
+	kept = kept[:n]
+	return kept, estimate(joinBlocks(kept))
+}
+
+// joinBlocks is the narrative of chunks as separate blocks
+func joinBlocks(chunks []Chunk) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		b.WriteString(c.Content)
+		b.WriteString(chunkSeparator)
+	}
+	return b.String()
+}
+
+// pickChunks keeps the chunks whose costs fit within budget, and within
+// opts.MaxNarrativeBytes
+func pickChunks(chunks []Chunk, costs []int, total, budget int, opts Options) []Chunk {
+	if opts.MaxTokens <= 0 || total <= budget {
+		return capBytes(chunks, opts.MaxNarrativeBytes)
+	}
+
+	keep := make([]bool, len(chunks))
+	remaining := budget
+
+	if opts.SectionQuotas {
+		remaining = fillSectionQuotas(chunks, costs, budget, keep)
+	}
+
+	// Greedy pass in rank order; with quotas this hands out whatever
+	// budget the sections left unused.
+	for i := range chunks {
+		if !keep[i] && costs[i] <= remaining {
+			keep[i] = true
+			remaining -= costs[i]
+		}
+	}
+
+	var selected []Chunk
+	for i, chunk := range chunks {
+		if keep[i] {
+			selected = append(selected, chunk)
+		}
+	}
+	return capBytes(selected, opts.MaxNarrativeBytes)
+}
+
+// capBytes drops the chunks that would take the narrative past limit bytes,
+// in rank order as the token budget does. A limit of zero or less means no
+// limit.
+func capBytes(chunks []Chunk, limit int) []Chunk {
+	if limit <= 0 {
+		return chunks
+	}
+	remaining := limit
+	var kept []Chunk
+	for _, c := range chunks {
+		if size := len(c.Content) + len(chunkSeparator); size <= remaining {
+			kept = append(kept, c)
+			remaining -= size
+		}
+	}
+	return kept
+}
+
+// fillSectionQuotas marks the chunks that fit in each section's share of
+// the budget and returns the budget left over
+func fillSectionQuotas(chunks []Chunk, costs []int, budget int, keep []bool) int {
+	counts := make(map[string]int)
+	for _, chunk := range chunks {
+		counts[chunk.Section]++
+	}
+
+	quotas := make(map[string]int, len(counts))
+	for section, n := range counts {
+		quotas[section] = budget * n / len(chunks)
+	}
+
+	used := 0
+	for i, chunk := range chunks {
+		if cost := costs[i]; cost <= quotas[chunk.Section] {
+			keep[i] = true
+			quotas[chunk.Section] -= cost
+			used += cost
+		}
+	}
+	return budget - used
+}
+
+// truncationNotice tells the reader of a narrative that n chunks, or
+// sentences in sentence mode, were left out to fit the token budget
+func truncationNotice(n int, sentences bool) string {
+	unit := "chunk"
+	if sentences {
+		unit = "sentence"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("[...%d %s omitted due to token budget...]", n, unit)
+}
@@ -0,0 +1,111 @@
+package aio
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestBudgetWithTruncationNotice(t *testing.T) {
+	doc := AIOFile{Version: "2.1"}
+	for i := 0; i < 12; i++ {
+		doc.Content = append(doc.Content, Chunk{
+			ID:      fmt.Sprintf("c%d", i),
+			Content: strings.TrimSpace(strings.Repeat("Pricing plans cover every team size. ", 1+i%4)),
+		})
+	}
+	data := mustEncode(t, &doc)
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "chunks", opts: Options{}},
+		{name: "sentences", opts: Options{TopSentences: 20}},
+		{name: "section quotas", opts: Options{SectionQuotas: true}},
+		{name: "narrative bytes", opts: Options{MaxNarrativeBytes: 400}},
+	}
+	for _, tt := range tests {
+		for _, notice := range []bool{false, true} {
+			for _, maxTokens := range []int{1, 8, 20, 40, 75, 120, 200, 10000} {
+				t.Run(fmt.Sprintf("%s/notice=%v/%d", tt.name, notice, maxTokens), func(t *testing.T) {
+					opts := tt.opts
+					opts.MaxTokens = maxTokens
+					opts.TruncationNotice = notice
+					env, err := ParseReader(strings.NewReader(string(data)), "pricing", opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if env.Tokens > maxTokens {
+						t.Errorf("Tokens = %d, over MaxTokens %d", env.Tokens, maxTokens)
+					}
+					hasNotice := strings.Contains(env.Narrative, "omitted due to token budget")
+					if hasNotice && (!notice || env.Stats.BudgetDropped == 0) {
+						t.Errorf("unexpected notice in %q", env.Narrative)
+					}
+					if notice && maxTokens >= 40 && env.Stats.BudgetDropped > 0 && !hasNotice {
+						t.Errorf("%d dropped but no notice in %q", env.Stats.BudgetDropped, env.Narrative)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTokensCountNarrative(t *testing.T) {
+	// Ten chunks of 9 bytes each cost 2 tokens on their own, but their
+	// 110-byte narrative costs 27
+	doc := AIOFile{Version: "2.1"}
+	for i := 0; i < 10; i++ {
+		doc.Content = append(doc.Content, Chunk{ID: fmt.Sprintf("c%d", i), Content: "pricing x"})
+	}
+	env, err := ParseReader(strings.NewReader(string(mustEncode(t, &doc))), "pricing", Options{MaxTokens: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := estimateTokens(env.Narrative); env.Tokens != got || got > 20 {
+		t.Errorf("Tokens = %d, narrative counts %d, MaxTokens 20", env.Tokens, got)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"pricing", "plans", "a", "seat", "monthly", "价格", "x", "team", "Berlin."}
+	for i := 0; i < 500; i++ {
+		doc := AIOFile{Version: "2.1", Language: []string{"", "en", "zh"}[rng.Intn(3)]}
+		for j := 0; j < 1+rng.Intn(15); j++ {
+			var text []string
+			for k := 0; k < 1+rng.Intn(20); k++ {
+				w := words[rng.Intn(len(words))]
+				if rng.Intn(5) == 0 {
+					w += "."
+				}
+				text = append(text, w)
+			}
+			doc.Content = append(doc.Content, Chunk{ID: fmt.Sprintf("c%d", j), Content: strings.Join(text, " "), Section: fmt.Sprint(j % 3)})
+		}
+		opts := Options{
+			MaxTokens:        rng.Intn(120),
+			TruncationNotice: rng.Intn(2) == 0,
+			SectionQuotas:    rng.Intn(3) == 0,
+			MergeAdjacent:    rng.Intn(3) == 0,
+		}
+		if rng.Intn(3) == 0 {
+			opts.TopSentences = 1 + rng.Intn(10)
+		}
+		estimate := estimatorFor(doc.Language)
+		if rng.Intn(3) == 0 {
+			opts.CharsPerToken = 2 + rng.Float64()*4
+			estimate = charsPerTokenEstimator(opts.CharsPerToken)
+		}
+		query := []string{"", "pricing", "plans seat"}[rng.Intn(3)]
+		env, err := ParseReader(strings.NewReader(string(mustEncode(t, &doc))), query, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := estimate(env.Narrative); env.Tokens != got {
+			t.Fatalf("case %d %+v: Tokens = %d, narrative counts %d", i, opts, env.Tokens, got)
+		}
+		if opts.MaxTokens > 0 && env.Tokens > opts.MaxTokens {
+			t.Fatalf("case %d %+v: Tokens = %d, over MaxTokens", i, opts, env.Tokens)
+		}
+	}
+}
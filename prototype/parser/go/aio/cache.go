@@ -0,0 +1,281 @@
+package aio
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores downloaded documents and pages between parses, keyed by
+// normalized URL. Entries past their Expires time are revalidated with
+// If-None-Match / If-Modified-Since and reused on 304 Not Modified.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the entry for url, or nil on a miss
+	Get(url string) *CacheEntry
+	Put(e *CacheEntry)
+}
+
+// CacheEntry is one cached response
+type CacheEntry struct {
+	URL string `json:"url"`
+
+	// ETag and LastModified are the validators to revalidate with
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Expires is when the entry stops being fresh, from the response's
+	// Cache-Control max-age or Expires header. Fresh entries are used
+	// without contacting the server; a zero Expires means every use is
+	// revalidated.
+	Expires time.Time `json:"expires"`
+
+	// Header holds the response headers, such as Link, that discovery
+	// reads from a cached page
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body"`
+
+	// Vary holds the request headers the response's Vary header names,
+	// with the values they had; the entry is only used for requests
+	// sending the same
+	Vary map[string]string `json:"vary,omitempty"`
+}
+
+// fresh reports whether e can be used at now without revalidation
+func (e *CacheEntry) fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// newCacheEntry records the response to req for the cache, or returns
+// nil when it may not be stored or can be neither revalidated nor reused
+// as fresh. The cache is shared by every parse, so, as for a shared cache
+// under RFC 9111, a response marked private, answering a request that
+// carried Authorization unless marked public, or varying on everything is
+// not stored.
+func newCacheEntry(url string, req *http.Request, header http.Header, body []byte, now time.Time) *CacheEntry {
+	directives := cacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+	if _, ok := directives["private"]; ok {
+		return nil
+	}
+	if _, ok := directives["public"]; !ok && req.Header.Get("Authorization") != "" {
+		return nil
+	}
+	vary, ok := varyValues(header, req.Header)
+	if !ok {
+		return nil
+	}
+	e := &CacheEntry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Expires:      freshUntil(header, directives, now),
+		Header:       header.Clone(),
+		Body:         body,
+		Vary:         vary,
+	}
+	if e.ETag == "" && e.LastModified == "" && e.Expires.IsZero() {
+		return nil
+	}
+	return e
+}
+
+// varyValues returns the values in request of the headers the Vary
+// header of response names, and false for "Vary: *"
+func varyValues(response, request http.Header) (map[string]string, bool) {
+	var vary map[string]string
+	for _, line := range response.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			switch name {
+			case "":
+				continue
+			case "*":
+				return nil, false
+			}
+			if vary == nil {
+				vary = map[string]string{}
+			}
+			vary[name] = strings.Join(request.Values(name), ", ")
+		}
+	}
+	return vary, true
+}
+
+// matches reports whether e can answer a request with header, sending
+// what the stored response varied on
+func (e *CacheEntry) matches(header http.Header) bool {
+	for name, value := range e.Vary {
+		if strings.Join(header.Values(name), ", ") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidated is e confirmed current by a 304 response, with the stored
+// headers updated from it as RFC 9111 describes
+func (e *CacheEntry) revalidated(header http.Header, now time.Time) *CacheEntry {
+	updated := *e
+	updated.Header = e.Header.Clone()
+	if updated.Header == nil {
+		updated.Header = http.Header{}
+	}
+	for k, v := range header {
+		updated.Header[k] = v
+	}
+	if etag := header.Get("ETag"); etag != "" {
+		updated.ETag = etag
+	}
+	if lm := header.Get("Last-Modified"); lm != "" {
+		updated.LastModified = lm
+	}
+	updated.Expires = freshUntil(header, cacheControl(header), now)
+	return &updated
+}
+
+// cacheControl parses the Cache-Control directives of header into a map
+// from lower-case name to value, empty for directives without one
+func cacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, line := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// freshUntil is when a response stops being fresh: max-age seconds after
+// now, or else its Expires date. no-cache, or neither header, gives the
+// zero time.
+func freshUntil(header http.Header, directives map[string]string, now time.Time) time.Time {
+	if _, ok := directives["no-cache"]; ok {
+		return time.Time{}
+	}
+	if v, ok := directives["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(secs) * time.Second)
+	}
+	if t, err := http.ParseTime(header.Get("Expires")); err == nil && t.After(now) {
+		return t
+	}
+	return time.Time{}
+}
+
+// DefaultMemoryCacheSize is the capacity of a MemoryCache created with a
+// size of zero
+const DefaultMemoryCacheSize = 128
+
+// MemoryCache is a Cache holding up to a fixed number of entries in
+// memory, evicting the least recently used. Set its fields before first
+// use.
+type MemoryCache struct {
+	// Compress holds bodies gzip-compressed, with the same threshold as
+	// FileCache.Compress, trading some CPU on every hit for room for
+	// more entries within MaxBytes
+	Compress bool
+
+	// MaxBytes bounds the bodies held, counted as stored, so compressed
+	// when Compress is set; the least recently used entries are evicted
+	// to stay within it. Zero means no limit beyond the entry count.
+	MaxBytes int64
+
+	mu      sync.Mutex
+	size    int
+	bytes   int64
+	order   *list.List // of *memoryEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+// memoryEntry is a MemoryCache entry as held, its body gzip-compressed
+// when zipped
+type memoryEntry struct {
+	CacheEntry
+	zipped bool
+}
+
+// NewMemoryCache returns an empty MemoryCache of up to size entries; zero
+// uses DefaultMemoryCacheSize
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DefaultMemoryCacheSize
+	}
+	return &MemoryCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *MemoryCache) Get(url string) *CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[url]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(el)
+	m := el.Value.(*memoryEntry)
+	e := m.CacheEntry
+	if m.zipped {
+		body, err := gunzipBody(e.Body)
+		if err != nil {
+			c.remove(el)
+			return nil
+		}
+		e.Body = body
+	}
+	return &e
+}
+
+func (c *MemoryCache) Put(e *CacheEntry) {
+	m := &memoryEntry{CacheEntry: *e}
+	if c.Compress {
+		if zipped := gzipBody(e.Body); zipped != nil {
+			m.Body, m.zipped = zipped, true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.URL]; ok {
+		c.remove(el)
+	}
+	if c.MaxBytes > 0 && int64(len(m.Body)) > c.MaxBytes {
+		return
+	}
+	c.entries[e.URL] = c.order.PushFront(m)
+	c.bytes += int64(len(m.Body))
+	for c.order.Len() > c.size || c.MaxBytes > 0 && c.bytes > c.MaxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops the entry of el; c.mu is held
+func (c *MemoryCache) remove(el *list.Element) {
+	m := el.Value.(*memoryEntry)
+	c.order.Remove(el)
+	delete(c.entries, m.URL)
+	c.bytes -= int64(len(m.Body))
+}
+
+// cacheFor returns the cache a parse under opts uses: Options.Cache, or
+// a FileCache under Options.CacheDir, or nil for none
+func cacheFor(opts Options) Cache {
+	switch {
+	case opts.Cache != nil:
+		return opts.Cache
+	case opts.CacheDir != "":
+		return &FileCache{Dir: opts.CacheDir, Compress: opts.CompressCache}
+	default:
+		return nil
+	}
+}
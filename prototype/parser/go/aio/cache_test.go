@@ -0,0 +1,109 @@
+package aio
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCacheEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  http.Header
+		response http.Header
+		stored   bool
+	}{
+		{name: "validator", response: http.Header{"Etag": {`"v1"`}}, stored: true},
+		{name: "no-store", response: http.Header{"Etag": {`"v1"`}, "Cache-Control": {"no-store"}}},
+		{name: "private", response: http.Header{"Etag": {`"v1"`}, "Cache-Control": {"private, max-age=60"}}},
+		{
+			name:     "authorized",
+			request:  http.Header{"Authorization": {"Bearer tok"}},
+			response: http.Header{"Etag": {`"v1"`}, "Cache-Control": {"max-age=60"}},
+		},
+		{
+			name:     "authorized but public",
+			request:  http.Header{"Authorization": {"Bearer tok"}},
+			response: http.Header{"Etag": {`"v1"`}, "Cache-Control": {"public, max-age=60"}},
+			stored:   true,
+		},
+		{name: "vary on everything", response: http.Header{"Etag": {`"v1"`}, "Vary": {"*"}}},
+		{name: "vary", response: http.Header{"Etag": {`"v1"`}, "Vary": {"Accept-Language"}}, stored: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "https://example.com/ai-content.aio", nil)
+			for k, v := range tt.request {
+				req.Header[k] = v
+			}
+			e := newCacheEntry("example.com/ai-content.aio", req, tt.response, []byte("{}"), time.Now())
+			if (e != nil) != tt.stored {
+				t.Errorf("stored = %v, want %v", e != nil, tt.stored)
+			}
+		})
+	}
+}
+
+func TestCacheEntryVary(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/ai-content.aio", nil)
+	req.Header.Set("Accept-Language", "de")
+	e := newCacheEntry("example.com/ai-content.aio", req, http.Header{"Etag": {`"v1"`}, "Vary": {"accept-language, Accept-Encoding"}}, nil, time.Now())
+	tests := []struct {
+		header http.Header
+		want   bool
+	}{
+		{http.Header{"Accept-Language": {"de"}}, true},
+		{http.Header{"Accept-Language": {"fr"}}, false},
+		{http.Header{}, false},
+		{http.Header{"Accept-Language": {"de"}, "Accept-Encoding": {"gzip"}}, false},
+	}
+	for _, tt := range tests {
+		if got := e.matches(tt.header); got != tt.want {
+			t.Errorf("matches(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryCacheCompress(t *testing.T) {
+	large := []byte(strings.Repeat("Three plans are available. ", 400))
+	small := []byte("{}")
+	tests := []struct {
+		name     string
+		compress bool
+		kept     int
+	}{
+		{name: "plain", kept: 1},
+		{name: "compressed", compress: true, kept: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache(0)
+			c.Compress = tt.compress
+			c.MaxBytes = int64(len(large) + len(small))
+			c.Put(&CacheEntry{URL: "small", Body: small})
+			for i := 0; i < 10; i++ {
+				c.Put(&CacheEntry{URL: fmt.Sprint(i), Body: large})
+			}
+			if c.bytes > c.MaxBytes {
+				t.Errorf("holds %d bytes, over MaxBytes %d", c.bytes, c.MaxBytes)
+			}
+			kept := 0
+			for i := 0; i < 10; i++ {
+				if e := c.Get(fmt.Sprint(i)); e != nil {
+					kept++
+					if string(e.Body) != string(large) {
+						t.Fatalf("entry %d came back as %d bytes", i, len(e.Body))
+					}
+				}
+			}
+			if kept != tt.kept {
+				t.Errorf("kept %d of 10 large entries, want %d", kept, tt.kept)
+			}
+			if el, ok := c.entries["small"]; tt.compress && (!ok || el.Value.(*memoryEntry).zipped) {
+				t.Error("a body under the threshold was not held as is")
+			}
+		})
+	}
+}
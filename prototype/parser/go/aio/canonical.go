@@ -0,0 +1,110 @@
+package aio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+)
+
+// envelopeID derives a stable envelope ID from the source and the chunks
+// selected, so identical parses produce identical IDs
+func envelopeID(sourceURL string, chunks []Chunk) string {
+	h := sha256.New()
+	h.Write([]byte(sourceURL))
+	for _, chunk := range chunks {
+		h.Write([]byte{0})
+		h.Write([]byte(chunk.ID))
+		h.Write([]byte{0})
+		h.Write([]byte(chunk.Hash))
+	}
+	return "aio-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Canonical serializes env for golden-file comparisons: items sorted by
+// ID, line endings and trailing whitespace normalized, keys sorted,
+// timing dropped, and the ID recomputed from the sorted items. Two parses
+// that differ only in ordering or incidental whitespace produce the same
+// string. Scores JSON cannot hold are written as the nearest it can: NaN
+// as 0 and an infinity as the largest float of its sign.
+func Canonical(env *ContentEnvelope) string {
+	c := *env
+	c.Timing = nil
+	c.Narrative = normalizeWhitespace(c.Narrative)
+	c.LanguageConfidence = finite(c.LanguageConfidence)
+	c.Items = make([]Chunk, len(env.Items))
+	for i, chunk := range env.Items {
+		chunk.Content = normalizeWhitespace(chunk.Content)
+		c.Items[i] = finiteChunk(chunk)
+	}
+	sort.SliceStable(c.Items, func(i, j int) bool {
+		return c.Items[i].ID < c.Items[j].ID
+	})
+	c.ID = envelopeID(c.SourceURL, c.Items)
+	if env.Groups != nil {
+		c.Groups = make(map[string][]Chunk, len(env.Groups))
+		for term, chunks := range env.Groups {
+			c.Groups[term] = finiteChunks(chunks)
+		}
+	}
+	c.Unmatched = finiteChunks(env.Unmatched)
+
+	// With every float finite, the envelope always marshals
+	raw, _ := json.Marshal(&c)
+	sorted, _ := canonicalJSON(raw)
+	var out bytes.Buffer
+	json.Indent(&out, sorted, "", "  ")
+	out.WriteByte('\n')
+	return out.String()
+}
+
+// finite maps the floats JSON cannot hold to the nearest it can
+func finite(f float64) float64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case math.IsInf(f, 1):
+		return math.MaxFloat64
+	case math.IsInf(f, -1):
+		return -math.MaxFloat64
+	}
+	return f
+}
+
+func finiteChunk(c Chunk) Chunk {
+	c.Score = finite(c.Score)
+	c.LanguageConfidence = finite(c.LanguageConfidence)
+	if c.Embedding != nil {
+		vec := make([]float32, len(c.Embedding))
+		for i, v := range c.Embedding {
+			vec[i] = float32(max(min(finite(float64(v)), math.MaxFloat32), -math.MaxFloat32))
+		}
+		c.Embedding = vec
+	}
+	return c
+}
+
+func finiteChunks(chunks []Chunk) []Chunk {
+	if chunks == nil {
+		return nil
+	}
+	out := make([]Chunk, len(chunks))
+	for i, c := range chunks {
+		out[i] = finiteChunk(c)
+	}
+	return out
+}
+
+// normalizeWhitespace converts CRLF to LF, strips trailing whitespace from
+// every line and trims leading and trailing blank lines
+func normalizeWhitespace(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,36 @@
+package aio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	a := &ContentEnvelope{SourceURL: "https://example.com", Narrative: "Text.  \r\n", Items: []Chunk{{ID: "b", Content: "B \n"}, {ID: "a", Content: "A"}}}
+	b := &ContentEnvelope{SourceURL: "https://example.com", Narrative: "Text.", Items: []Chunk{{ID: "a", Content: "A"}, {ID: "b", Content: "B"}}}
+	ca, cb := Canonical(a), Canonical(b)
+	if ca != cb {
+		t.Errorf("envelopes differing in order and whitespace canonicalize differently:\n%s\n%s", ca, cb)
+	}
+}
+
+func TestCanonicalNonFinite(t *testing.T) {
+	tests := []struct {
+		score, want float64
+	}{
+		{math.NaN(), 0},
+		{math.Inf(1), math.MaxFloat64},
+		{math.Inf(-1), -math.MaxFloat64},
+	}
+	for _, tt := range tests {
+		env := &ContentEnvelope{Items: []Chunk{{ID: "a", Content: "A", Score: tt.score, Embedding: []float32{float32(tt.score)}}}}
+		got := Canonical(env)
+		want := Canonical(&ContentEnvelope{Items: []Chunk{{ID: "a", Content: "A", Score: tt.want, Embedding: []float32{float32(max(min(tt.want, math.MaxFloat32), -math.MaxFloat32))}}}})
+		if got != want {
+			t.Errorf("score %v canonicalized as\n%s\nwant\n%s", tt.score, got, want)
+		}
+		if math.IsNaN(env.Items[0].Score) != math.IsNaN(tt.score) {
+			t.Error("the envelope was modified")
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package aio
+
+import "sort"
+
+// CapabilitySet describes what this parser supports, for integrators that
+// adapt to the parser version they are linked against
+type CapabilitySet struct {
+	// MinVersion and MaxVersion bound the aio_version values the parser
+	// is written against
+	MinVersion string `json:"min_aio_version"`
+	MaxVersion string `json:"max_aio_version"`
+
+	// Formats are the inputs the parser accepts
+	Formats []string `json:"formats"`
+
+	// Sources are the strategies available to Options.FallbackChain
+	Sources []SourceStrategy `json:"sources"`
+
+	// MatchModes are the ways a query term can match
+	MatchModes []string `json:"match_modes"`
+
+	// HashAlgorithms are the chunk hash algorithms that can be verified
+	HashAlgorithms []string `json:"hash_algorithms"`
+
+	// Features lists optional behaviours by name
+	Features []string `json:"features"`
+}
+
+// Capabilities reports the parser's supported versions, inputs and
+// features. Add to it alongside any new user-visible feature.
+func Capabilities() CapabilitySet {
+	hashes := make([]string, 0, len(hashAlgorithms))
+	for name := range hashAlgorithms {
+		hashes = append(hashes, name)
+	}
+	sort.Strings(hashes)
+
+	return CapabilitySet{
+		MinVersion: minVersion,
+		MaxVersion: maxVersion,
+		Formats:    []string{"aio+json", "aio+cbor", "aio+json.gz", "zip", "tar", "tar.gz", "html"},
+		Sources: []SourceStrategy{
+			StrategyDirectURL, StrategyWellKnown, StrategyLinkHeader, StrategyLinkElement, StrategySitemap, StrategyScrape,
+		},
+		MatchModes:     []string{"substring", "wildcard", "boost"},
+		HashAlgorithms: hashes,
+		Features: []string{
+			"signatures",
+			"pagination",
+			"token-budget",
+			"section-quotas",
+			"top-sentences",
+			"related-chunks",
+			"refs",
+			"templates",
+			"suggestions",
+			"stopwords",
+			"staleness",
+			"intent",
+			"tags",
+			"group-by-keyword",
+			"inline-keywords",
+			"citations",
+			"merge-scraped",
+			"mirrors",
+			"rate-limit-retry",
+			"disk-cache",
+			"resume",
+			"search-results",
+			"sse",
+			"schema",
+			"multi-query",
+			"offset-count",
+			"language-detection",
+			"keyword-cloud",
+			"scheme-upgrade",
+			"truncation-notice",
+			"tie-breakers",
+			"source-loaders",
+			"health-checks",
+			"compressed-cache",
+			"unmatched-chunks",
+			"webhooks",
+			"custom-extractors",
+			"discover",
+			"validation",
+			"strict-hashes",
+			"bm25",
+			"min-score",
+			"tokenizers",
+			"stream-decode",
+			"chunk-limits",
+			"memory-cache",
+			"cache-control",
+			"batch-stream",
+			"host-limits",
+			"generate",
+			"document-server",
+			"robots-txt",
+			"metadata",
+			"version-migration",
+			"multi-document",
+			"semantic-retrieval",
+			"render-formats",
+			"mcp-server",
+			"provenance",
+			"compressed-payloads",
+			"sanitizer",
+			"private-network-guard",
+			"change-tracking",
+			"observability",
+			"language-filter",
+			"stemming",
+			"summarizer",
+			"credentials",
+			"conformance",
+			"export",
+		},
+	}
+}
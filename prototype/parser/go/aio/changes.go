@@ -0,0 +1,117 @@
+package aio
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Change is how a chunk differs from the previous parse of its document
+// (Options.TrackChanges)
+type Change string
+
+const (
+	ChangeAdded     Change = "added"
+	ChangeModified  Change = "changed"
+	ChangeUnchanged Change = "unchanged"
+)
+
+// ChangeSet compares a document with the previous parse of it, by chunk
+// ID and content hash, so a vector store can apply just the difference:
+// embed Added and Changed, delete Removed. On the first parse every chunk
+// is Added.
+type ChangeSet struct {
+	// Since is when the previous parse was taken (RFC 3339); empty on the
+	// first
+	Since string `json:"since,omitempty"`
+
+	Added     []string `json:"added"`
+	Changed   []string `json:"changed"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// snapshotKeyPrefix keys the chunk hashes of a document in the Cache,
+// apart from the responses stored under their URLs
+const snapshotKeyPrefix = "aio-snapshot:"
+
+// snapshot is what change tracking keeps of a parse
+type snapshot struct {
+	Taken  time.Time         `json:"taken"`
+	Hashes map[string]string `json:"hashes"` // chunk ID to content hash
+}
+
+// changeTracker holds one document's comparison with its snapshot
+type changeTracker struct {
+	set     *ChangeSet
+	changes map[string]Change
+}
+
+// trackChanges compares the chunks of aio, the content read from
+// docURL, with the snapshot the cache holds of the previous parse, and
+// replaces the snapshot with this one. Without a cache there is nothing
+// to compare with and every chunk is added.
+func trackChanges(aio *AIOFile, docURL string, cache Cache, now time.Time) *changeTracker {
+	key := snapshotKeyPrefix + normalizeURL(docURL)
+	var prev snapshot
+	if cache != nil {
+		if e := cache.Get(key); e != nil {
+			json.Unmarshal(e.Body, &prev)
+		}
+	}
+
+	t := &changeTracker{set: &ChangeSet{Added: []string{}, Changed: []string{}, Removed: []string{}}, changes: map[string]Change{}}
+	if !prev.Taken.IsZero() {
+		t.set.Since = prev.Taken.UTC().Format(time.RFC3339)
+	}
+	next := snapshot{Taken: now, Hashes: make(map[string]string, len(aio.Content))}
+	for _, c := range aio.Content {
+		hash := sha256Hex(c.Content)
+		next.Hashes[c.ID] = hash
+		old, seen := prev.Hashes[c.ID]
+		switch {
+		case !seen:
+			t.changes[c.ID] = ChangeAdded
+			t.set.Added = append(t.set.Added, c.ID)
+		case old != hash:
+			t.changes[c.ID] = ChangeModified
+			t.set.Changed = append(t.set.Changed, c.ID)
+		default:
+			t.changes[c.ID] = ChangeUnchanged
+			t.set.Unchanged++
+		}
+	}
+	for id := range prev.Hashes {
+		if _, ok := next.Hashes[id]; !ok {
+			t.set.Removed = append(t.set.Removed, id)
+		}
+	}
+	sort.Strings(t.set.Removed)
+
+	if cache != nil {
+		if body, err := json.Marshal(next); err == nil {
+			// The snapshot is never fetched, so it stays fresh until the
+			// next parse replaces it
+			cache.Put(&CacheEntry{URL: key, Expires: now.AddDate(100, 0, 0), Body: body})
+		}
+	}
+	return t
+}
+
+// filter narrows a chunk filter to the chunks added or changed since the
+// snapshot (Options.ChangedOnly)
+func (t *changeTracker) filter(next func(Chunk) bool) func(Chunk) bool {
+	return func(c Chunk) bool {
+		if t.changes[c.ID] == ChangeUnchanged {
+			return false
+		}
+		return next == nil || next(c)
+	}
+}
+
+// annotate marks each selected chunk with its change
+func (t *changeTracker) annotate(chunks []Chunk) {
+	for i := range chunks {
+		chunks[i].Change = t.changes[chunks[i].ID]
+	}
+}
@@ -0,0 +1,165 @@
+package aio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// citation returns a link to the chunk's lines in the page at sourceURL,
+// in the #L42-L67 fragment form code hosts use, or "" when the chunk has
+// no line metadata. An existing fragment on sourceURL is replaced.
+func citation(sourceURL string, c Chunk) string {
+	if c.LineStart <= 0 {
+		return ""
+	}
+	base, _, _ := strings.Cut(sourceURL, "#")
+	if c.LineEnd <= c.LineStart {
+		return fmt.Sprintf("%s#L%d", base, c.LineStart)
+	}
+	return fmt.Sprintf("%s#L%d-L%d", base, c.LineStart, c.LineEnd)
+}
+
+// Provenance records where a selected chunk came from and why it was
+// selected, so an answer built from it can cite it precisely
+type Provenance struct {
+	// URL is where the chunk can be cited: the page its index entry names,
+	// its lines in the page, the document it was merged from, or the parsed
+	// URL. Anchor is URL's fragment, e.g. "pricing" or "L42-L67".
+	URL    string `json:"url"`
+	Anchor string `json:"anchor,omitempty"`
+
+	// Title names the source for a reader: the chunk's index title, or
+	// else its section
+	Title   string `json:"title,omitempty"`
+	Section string `json:"section,omitempty"`
+
+	// Position is the chunk's place in the document's content array, and
+	// the line and byte offsets locate it in the source page when the
+	// document records them
+	Position  int `json:"position"`
+	LineStart int `json:"line_start,omitempty"`
+	LineEnd   int `json:"line_end,omitempty"`
+	ByteStart int `json:"byte_start,omitempty"`
+	ByteEnd   int `json:"byte_end,omitempty"`
+
+	// MatchedTerms are the query terms the chunk matched, and
+	// MatchedKeywords the index keywords among them that selected it
+	MatchedTerms    []string `json:"matched_terms,omitempty"`
+	MatchedKeywords []string `json:"matched_keywords,omitempty"`
+
+	// Score is the chunk's retrieval score; zero when no query was given
+	Score float64 `json:"score,omitempty"`
+}
+
+// provenance describes a selected chunk read from the page at sourceURL,
+// once its Citation is set
+func provenance(sourceURL string, c Chunk, entry *IndexEntry, terms []queryTerm) *Provenance {
+	p := &Provenance{
+		Section:      c.Section,
+		Position:     c.pos,
+		LineStart:    c.LineStart,
+		LineEnd:      c.LineEnd,
+		ByteStart:    c.ByteStart,
+		ByteEnd:      c.ByteEnd,
+		MatchedTerms: c.matchedTerms,
+		Score:        c.Score,
+	}
+	if entry != nil {
+		p.Title = entry.Title
+	}
+	if p.Title == "" {
+		p.Title = c.Section
+	}
+
+	switch {
+	case entry != nil && entry.Path != "":
+		if u, err := resolveReference(sourceURL, entry.Path); err == nil {
+			p.URL = u
+		}
+	case c.Citation != "":
+		p.URL = c.Citation
+	case c.SourceURL != "":
+		p.URL = c.SourceURL
+	}
+	if p.URL == "" {
+		p.URL = sourceURL
+	}
+	_, p.Anchor, _ = strings.Cut(p.URL, "#")
+
+	if len(c.matchedTerms) > 0 {
+		for _, k := range chunkKeywords(entry) {
+			for _, t := range terms {
+				if t.matches(k) {
+					p.MatchedKeywords = append(p.MatchedKeywords, k)
+					break
+				}
+			}
+		}
+	}
+	return p
+}
+
+// CitationMap numbers the sources of an envelope's chunks for inline
+// footnotes: the answer marks a claim with Marker(chunk ID), and the
+// Markdown definitions go at its end. Chunks cited at the same URL share
+// a footnote.
+type CitationMap struct {
+	Footnotes []Footnote `json:"footnotes"`
+
+	// Chunks maps each chunk ID to its footnote number
+	Chunks map[string]int `json:"chunks"`
+}
+
+// Footnote is one numbered source of a CitationMap
+type Footnote struct {
+	Number   int      `json:"number"`
+	URL      string   `json:"url"`
+	Title    string   `json:"title,omitempty"`
+	ChunkIDs []string `json:"chunk_ids"`
+}
+
+// Citations numbers the sources of the envelope's selected chunks from 1,
+// in Items order
+func (e *ContentEnvelope) Citations() *CitationMap {
+	m := &CitationMap{Footnotes: []Footnote{}, Chunks: map[string]int{}}
+	byURL := map[string]int{}
+	for _, c := range e.Items {
+		url, title := e.chunkSource(c), c.Section
+		if c.Provenance != nil {
+			url, title = c.Provenance.URL, c.Provenance.Title
+		}
+		n, ok := byURL[url]
+		if !ok {
+			n = len(m.Footnotes) + 1
+			byURL[url] = n
+			m.Footnotes = append(m.Footnotes, Footnote{Number: n, URL: url, Title: title})
+		}
+		m.Footnotes[n-1].ChunkIDs = append(m.Footnotes[n-1].ChunkIDs, c.ID)
+		m.Chunks[c.ID] = n
+	}
+	return m
+}
+
+// Marker is the Markdown footnote reference for a chunk, e.g. "[^2]", or
+// "" for a chunk the map does not cite
+func (m *CitationMap) Marker(chunkID string) string {
+	n, ok := m.Chunks[chunkID]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("[^%d]", n)
+}
+
+// Markdown writes the footnote definitions, one per line, e.g.
+// "[^1]: Pricing, <https://example.com/pricing#plans>"
+func (m *CitationMap) Markdown() string {
+	var b strings.Builder
+	for _, f := range m.Footnotes {
+		if f.Title != "" {
+			fmt.Fprintf(&b, "[^%d]: %s, <%s>\n", f.Number, f.Title, f.URL)
+		} else {
+			fmt.Fprintf(&b, "[^%d]: <%s>\n", f.Number, f.URL)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package aio
+
+import (
+	"sort"
+	"strings"
+)
+
+// KeywordFreq is one keyword of a document's index and how many index
+// entries list it
+type KeywordFreq struct {
+	Keyword string `json:"keyword"`
+	Count   int    `json:"count"`
+}
+
+// KeywordCloud aggregates the index keywords of aio, most frequent first
+// and alphabetically among equals. Keywords are compared lower-cased and
+// trimmed, and count once per entry however often it repeats them.
+func KeywordCloud(aio *AIOFile) []KeywordFreq {
+	counts := map[string]int{}
+	for _, entry := range aio.Index {
+		seen := map[string]bool{}
+		for _, k := range entry.Keywords {
+			k = strings.ToLower(strings.TrimSpace(k))
+			if k == "" || seen[k] {
+				continue
+			}
+			seen[k] = true
+			counts[k]++
+		}
+	}
+
+	cloud := make([]KeywordFreq, 0, len(counts))
+	for k, n := range counts {
+		cloud = append(cloud, KeywordFreq{Keyword: k, Count: n})
+	}
+	sort.Slice(cloud, func(i, j int) bool {
+		if cloud[i].Count != cloud[j].Count {
+			return cloud[i].Count > cloud[j].Count
+		}
+		return cloud[i].Keyword < cloud[j].Keyword
+	})
+	return cloud
+}
@@ -0,0 +1,38 @@
+package aio
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables read by LoadConfigFromEnv
+const (
+	EnvTimeout     = "AIO_TIMEOUT"     // duration ("10s") or whole seconds ("10")
+	EnvUserAgent   = "AIO_USER_AGENT"  // User-Agent header
+	EnvMaxBytes    = "AIO_MAX_BYTES"   // maximum document size in bytes
+	EnvConcurrency = "AIO_CONCURRENCY" // parallel fetches for batch operations
+)
+
+// LoadConfigFromEnv returns Options with the transport defaults taken from
+// the AIO_* environment variables. Unset or malformed variables leave the
+// built-in default (the zero value) in place. Callers apply explicit
+// options or flags on top, giving the precedence flags > env > defaults.
+func LoadConfigFromEnv() Options {
+	var opts Options
+	if v := os.Getenv(EnvTimeout); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			opts.Timeout = d
+		} else if secs, err := strconv.Atoi(v); err == nil {
+			opts.Timeout = time.Duration(secs) * time.Second
+		}
+	}
+	opts.UserAgent = os.Getenv(EnvUserAgent)
+	if n, err := strconv.ParseInt(os.Getenv(EnvMaxBytes), 10, 64); err == nil {
+		opts.MaxBytes = n
+	}
+	if n, err := strconv.Atoi(os.Getenv(EnvConcurrency)); err == nil {
+		opts.Concurrency = n
+	}
+	return opts
+}
@@ -0,0 +1,167 @@
+package aio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A conformance corpus is a directory of .aio documents, each optionally
+// beside a <name>.case.json saying what parsing it must produce and a
+// <name>.golden.json holding the Canonical envelope it must produce. A
+// document without a case file is expected to parse and validate without
+// errors, so a generator's output can be checked as it stands.
+const (
+	caseSuffix   = ".case.json"
+	goldenSuffix = ".golden.json"
+)
+
+// ConformanceCase is what a conformance fixture expects
+type ConformanceCase struct {
+	Description string `json:"description,omitempty"`
+
+	// Query, MaxTokens, StrictHashes and MaxChunkBytes are the parse's
+	// query and options
+	Query         string `json:"query,omitempty"`
+	MaxTokens     int    `json:"max_tokens,omitempty"`
+	StrictHashes  bool   `json:"strict_hashes,omitempty"`
+	MaxChunkBytes int    `json:"max_chunk_bytes,omitempty"`
+
+	// Error is the kind of error the parse must fail with, e.g.
+	// "malformed", "unsupported_version", "integrity" or "too_large";
+	// empty when it must succeed
+	Error string `json:"error,omitempty"`
+
+	// Issues are the ValidateDocument findings expected, in order, each as
+	// its severity and field, e.g. "error content[1].id". Nil expects no
+	// errors and accepts any warnings.
+	Issues []string `json:"issues,omitempty"`
+}
+
+// ConformanceResult is the outcome of one fixture
+type ConformanceResult struct {
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
+
+	// Updated is set when the golden envelope was written rather than
+	// compared
+	Updated bool `json:"updated,omitempty"`
+}
+
+// RunConformance checks every fixture of the corpus in dir, in name
+// order. With update, the golden envelopes of fixtures that parse are
+// written rather than compared, to record an intended change of output.
+// It fails only when the corpus cannot be read.
+func RunConformance(dir string, update bool) ([]ConformanceResult, error) {
+	docs, err := filepath.Glob(filepath.Join(dir, "*.aio"))
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no .aio fixtures in %s", dir)
+	}
+	sort.Strings(docs)
+	results := make([]ConformanceResult, 0, len(docs))
+	for _, doc := range docs {
+		r, err := runConformanceCase(strings.TrimSuffix(doc, ".aio"), update)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// runConformanceCase checks the fixture at base, the path of its .aio
+// without the extension
+func runConformanceCase(base string, update bool) (ConformanceResult, error) {
+	result := ConformanceResult{Name: filepath.Base(base)}
+	fail := func(format string, args ...any) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+	data, err := os.ReadFile(base + ".aio")
+	if err != nil {
+		return result, err
+	}
+	var c ConformanceCase
+	if spec, err := os.ReadFile(base + caseSuffix); err == nil {
+		if err := json.Unmarshal(spec, &c); err != nil {
+			return result, fmt.Errorf("%s%s: %w", base, caseSuffix, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return result, err
+	}
+
+	issues := ValidateDocument(data)
+	if c.Issues != nil {
+		got := make([]string, len(issues))
+		for i, issue := range issues {
+			got[i] = string(issue.Severity) + " " + issue.Field
+		}
+		if strings.Join(got, "\n") != strings.Join(c.Issues, "\n") {
+			fail("validation: got issues %q, want %q", got, c.Issues)
+		}
+	} else if HasErrors(issues) && c.Error == "" {
+		for _, issue := range issues {
+			if issue.Severity == SeverityError {
+				fail("validation: %s", issue)
+			}
+		}
+	}
+
+	opts := Options{MaxTokens: c.MaxTokens, StrictHashes: c.StrictHashes, MaxChunkBytes: c.MaxChunkBytes}
+	env, err := ParseReader(bytes.NewReader(data), c.Query, opts)
+	switch {
+	case err != nil && c.Error == "":
+		fail("parse: %v", err)
+	case err != nil && errorKind(err) != c.Error:
+		fail("parse: got a %s error, want %s: %v", errorKind(err), c.Error, err)
+	case err == nil && c.Error != "":
+		fail("parse: succeeded, want a %s error", c.Error)
+	case err == nil:
+		golden := Canonical(env)
+		want, readErr := os.ReadFile(base + goldenSuffix)
+		switch {
+		case update:
+			if err := os.WriteFile(base+goldenSuffix, []byte(golden), 0o644); err != nil {
+				return result, err
+			}
+			result.Updated = true
+		case os.IsNotExist(readErr):
+			// Fixtures without a golden file check only errors and issues
+		case readErr != nil:
+			return result, readErr
+		case string(want) != golden:
+			fail("envelope differs from %s:\n%s", filepath.Base(base)+goldenSuffix, lineDiff(string(want), golden))
+		}
+	}
+	result.Passed = len(result.Failures) == 0
+	return result, nil
+}
+
+// lineDiff lists the lines of want and got that differ, by line number,
+// enough to see where an envelope drifted from its golden file
+func lineDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	shown := 0
+	for i := 0; i < max(len(w), len(g)) && shown < 10; i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			fmt.Fprintf(&b, "  line %d:\n    - %s\n    + %s\n", i+1, wl, gl)
+			shown++
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,18 @@
+package aio
+
+import "testing"
+
+func TestConformance(t *testing.T) {
+	results, err := RunConformance("../conformance", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no conformance cases found")
+	}
+	for _, r := range results {
+		if !r.Passed {
+			t.Errorf("%s: %v", r.Name, r.Failures)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package aio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ParseReader parses one AIO document read from r, without discovery or
+// fetching. Under Options.StreamDecode the content array is decoded chunk
+// by chunk and chunks that cannot match the query are dropped as they
+// arrive, so a large document is never held in memory whole.
+func ParseReader(r io.Reader, query string, opts Options) (*ContentEnvelope, error) {
+	return parseAIO(r, "", query, opts)
+}
+
+// streams reports whether a document can be decoded incrementally under
+// opts. A signature covers the whole document, so checking one needs all
+// of its bytes.
+func streams(opts Options) bool {
+	return opts.StreamDecode && opts.PublicKey == nil && !opts.RequireSignature
+}
+
+// skipLeadingNoise is trimLeadingNoise for a stream: the decoder skips
+// leading whitespace but not a byte order mark, which may follow some
+func skipLeadingNoise(br *bufio.Reader) {
+	for {
+		head, _ := br.Peek(len(utf8BOM))
+		switch {
+		case len(head) > 0 && (head[0] == ' ' || head[0] == '\t' || head[0] == '\r' || head[0] == '\n'):
+			br.Discard(1)
+		case bytes.Equal(head, utf8BOM):
+			br.Discard(len(utf8BOM))
+		default:
+			return
+		}
+	}
+}
+
+// decodeStream decodes a document from r one content chunk at a time,
+// keeping the chunks keep accepts. keep sees the chunk's index entry when
+// the index came before the content array, and nil otherwise. The limits
+// of opts are checked as each chunk arrives.
+func decodeStream(r io.Reader, keep func(Chunk, *IndexEntry) bool, opts Options) (*AIOFile, error) {
+	br := bufio.NewReader(r)
+	skipLeadingNoise(br)
+	// A packed document is unpacked whole and then decoded as a stream
+	if head, _ := br.Peek(len(zstdMagic)); bytes.HasPrefix(head, gzipMagic) || bytes.HasPrefix(head, zstdMagic) || isCBOR(head) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = unpack(data, opts.MaxBytes); err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(bytes.NewReader(data))
+		skipLeadingNoise(br)
+	}
+	dec := json.NewDecoder(br)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, shapeError(err)
+	}
+	if got := tokenType(tok); got != "an object" {
+		return nil, &ShapeError{Field: "the document", Want: "an object", Got: got}
+	}
+
+	var aio AIOFile
+	entries := map[string]*IndexEntry{}
+	sawContent := false
+	top := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, shapeError(err)
+		}
+		key, _ := tok.(string)
+		if key == "content" {
+			sawContent = true
+			if err := decodeContent(dec, &aio, entries, keep, opts); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Other fields are small next to the content, so each is decoded
+		// whole through AIOFile's own tags
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, shapeError(err)
+		}
+		if got := jsonType(raw); (key == "index" || key == "documents") && got != "an array" && got != "null" {
+			return nil, &ShapeError{Field: key, Want: "an array", Got: got}
+		}
+		top[key] = raw
+		if key == "aio_version" {
+			// Reject an unsupported version before reading on
+			if _, _, err := migrate(map[string]json.RawMessage{key: raw}); err != nil {
+				return nil, err
+			}
+		}
+		field, err := json.Marshal(map[string]json.RawMessage{key: raw})
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(field, &aio); err != nil {
+			return nil, shapeError(err)
+		}
+		if key == "index" {
+			entries = indexByID(aio.Index)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, shapeError(err)
+	}
+	// As when the document is decoded whole, only whitespace may follow
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: data after the document", ErrMalformed)
+	}
+	if !sawContent && isSidecar(top) {
+		return decodeSidecar(top, keep, opts)
+	}
+	if !sawContent && len(aio.Documents) == 0 {
+		return nil, &ShapeError{Field: "content", Want: "an array", Got: "nothing (the field is missing)"}
+	}
+	if _, aio.version, err = migrate(top); err != nil {
+		return nil, err
+	}
+	if aio.version.Migrated {
+		aio.Version = aio.version.Negotiated
+	}
+	return &aio, nil
+}
+
+// decodeSidecar finishes streaming decoding of a 1.x per-page document,
+// whose fields, having no content array, were all held as they arrived
+func decodeSidecar(top map[string]json.RawMessage, keep func(Chunk, *IndexEntry) bool, opts Options) (*AIOFile, error) {
+	migrated, version, err := migrate(top)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(migrated)
+	if err != nil {
+		return nil, err
+	}
+	aio := AIOFile{version: version}
+	if err := json.Unmarshal(data, &aio); err != nil {
+		return nil, shapeError(err)
+	}
+	if err := checkLimits(aio.Content, opts); err != nil {
+		return nil, err
+	}
+	if opts.StrictHashes {
+		if err := checkDocumentHashes(aio.Content); err != nil {
+			return nil, err
+		}
+	}
+	entries := indexByID(aio.Index)
+	content := aio.Content[:0]
+	for _, c := range aio.Content {
+		if keep(c, entries[c.ID]) {
+			content = append(content, c)
+		} else {
+			aio.dropped++
+		}
+	}
+	aio.Content = content
+	return &aio, nil
+}
+
+// decodeContent reads the elements of the content array, the decoder
+// positioned just before its opening bracket
+func decodeContent(dec *json.Decoder, aio *AIOFile, entries map[string]*IndexEntry, keep func(Chunk, *IndexEntry) bool, opts Options) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if got := tokenType(tok); got != "an array" {
+		return &ShapeError{Field: "content", Want: "an array", Got: got}
+	}
+
+	for i := 0; dec.More(); i++ {
+		if opts.MaxChunks > 0 && i == opts.MaxChunks {
+			return tooManyChunks(opts.MaxChunks)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		field := fmt.Sprintf("content[%d]", i)
+		if got := jsonType(raw); got != "an object" {
+			return &ShapeError{Field: field, Want: "an object", Got: got}
+		}
+		var chunk Chunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			err = shapeError(err)
+			var se *ShapeError
+			if errors.As(err, &se) {
+				se.Field = field + "." + se.Field
+			}
+			return err
+		}
+		if err := checkChunkSize(chunk, opts); err != nil {
+			return err
+		}
+		if opts.StrictHashes {
+			if err := checkDocumentHashes([]Chunk{chunk}); err != nil {
+				return err
+			}
+		}
+		if keep(chunk, entries[chunk.ID]) {
+			aio.Content = append(aio.Content, chunk)
+		} else {
+			aio.dropped++
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// tokenType names the JSON type a token starts, in the form jsonType uses
+func tokenType(tok json.Token) string {
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '[' {
+			return "an array"
+		}
+		return "an object"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	default:
+		return "a number"
+	}
+}
+
+// queryFilter returns the early filter of streaming decoding: with a
+// query, only chunks matching at least one of its terms in some field.
+// Chunks a Retriever may match without sharing a word with the query are
+// all kept.
+func queryFilter(query string, opts Options) func(Chunk, *IndexEntry) bool {
+	terms := queryTerms(query, opts)
+	if len(terms) == 0 || opts.Retriever != nil {
+		return func(Chunk, *IndexEntry) bool { return true }
+	}
+	weights := opts.FieldWeights.orDefault()
+	return func(c Chunk, entry *IndexEntry) bool {
+		_, matched := scoreChunk(c, entry, chunkKeywords(entry), terms, weights)
+		return len(matched) > 0
+	}
+}
+
+// checkLimits applies Options.MaxChunks and Options.MaxChunkBytes to a
+// document decoded whole
+func checkLimits(content []Chunk, opts Options) error {
+	if opts.MaxChunks > 0 && len(content) > opts.MaxChunks {
+		return tooManyChunks(opts.MaxChunks)
+	}
+	for _, c := range content {
+		if err := checkChunkSize(c, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkChunkSize(c Chunk, opts Options) error {
+	if opts.MaxChunkBytes > 0 && len(c.Content) > opts.MaxChunkBytes {
+		return fmt.Errorf("%w: chunk %q has %d bytes of content, more than %d", ErrTooLarge, c.ID, len(c.Content), opts.MaxChunkBytes)
+	}
+	return nil
+}
+
+func tooManyChunks(limit int) error {
+	return fmt.Errorf("%w: more than %d chunks", ErrTooLarge, limit)
+}
+
+// limitedReader fails with ErrTooLarge once more than n bytes are read,
+// where io.LimitReader would end the input quietly
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,62 @@
+package aio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLeadingNoise(t *testing.T) {
+	const doc = `{"aio_version": "2.1", "content": [{"id": "a", "content": "Text."}]}`
+	bom := string(utf8BOM)
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{"none", ""},
+		{"bom", bom},
+		{"whitespace", " \t\r\n\n"},
+		{"bom then whitespace", bom + "\r\n  "},
+		{"whitespace then bom", "\n" + bom},
+		{"two boms", bom + bom},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stream=%v", tt.name, stream), func(t *testing.T) {
+				env, err := ParseReader(strings.NewReader(tt.prefix+doc), "", Options{StreamDecode: stream})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(env.Items) != 1 || env.Items[0].Content != "Text." {
+					t.Errorf("got items %+v", env.Items)
+				}
+			})
+		}
+	}
+}
+
+// FuzzParseReader checks that no document panics either decoder and that
+// the stream decoder accepts exactly the documents the whole-document
+// decoder does
+func FuzzParseReader(f *testing.F) {
+	seeds, err := filepath.Glob("../conformance/*.aio")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range seeds {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, wholeErr := ParseReader(strings.NewReader(string(data)), "", Options{})
+		_, streamErr := ParseReader(strings.NewReader(string(data)), "", Options{StreamDecode: true})
+		if (wholeErr == nil) != (streamErr == nil) {
+			t.Errorf("decoders disagree: whole %v, stream %v", wholeErr, streamErr)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package aio
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DiscoveryChain is the order in which Discover looks for a site's AIO
+// document: the locations the site declares come before the conventional
+// paths, and the guessed ai-content.aio path comes last
+var DiscoveryChain = []SourceStrategy{
+	StrategyLinkHeader,
+	StrategyLinkElement,
+	StrategyWellKnown,
+	StrategyDirectURL,
+}
+
+// Discovery is where Discover found a site's AIO document
+type Discovery struct {
+	URL    string         `json:"url"`
+	Method SourceStrategy `json:"method"`
+}
+
+// Discover finds the AIO document for the site at url without parsing it
+func Discover(url string) (*Discovery, error) {
+	return defaultParser.Discover(context.Background(), url)
+}
+
+// Discover walks DiscoveryChain for url, or Options.FallbackChain when
+// set, and reports the first document that could be fetched. Scraping
+// finds no document and is skipped.
+func (p *Parser) Discover(ctx context.Context, url string) (*Discovery, error) {
+	chain := DiscoveryChain
+	if len(p.Options.FallbackChain) > 0 {
+		chain = nil
+		for _, s := range p.Options.FallbackChain {
+			if s != StrategyScrape {
+				chain = append(chain, s)
+			}
+		}
+	}
+	url, urlAuth := stripCredentials(url)
+	sess, err := newSession(ctx, p.Options, p.Client, url, urlAuth, p.newRun(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer sess.close()
+	src, err := locate(sess, normalizeURL(url), chain)
+	if err != nil {
+		return nil, err
+	}
+	return &Discovery{URL: src.url, Method: src.strategy}, nil
+}
+
+// linkElement returns the href of the first <link> in page whose rel
+// includes rel, or "". Only the head is searched where the page has one.
+func linkElement(page []byte, rel string) string {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return ""
+	}
+	root := findElement(doc, "head")
+	if root == nil {
+		root = doc
+	}
+	var find func(n *html.Node) string
+	find = func(n *html.Node) string {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			var rels, href string
+			for _, a := range n.Attr {
+				switch strings.ToLower(a.Key) {
+				case "rel":
+					rels = a.Val
+				case "href":
+					href = strings.TrimSpace(a.Val)
+				}
+			}
+			for _, r := range strings.Fields(rels) {
+				if strings.EqualFold(r, rel) && href != "" {
+					return href
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if href := find(c); href != "" {
+				return href
+			}
+		}
+		return ""
+	}
+	return find(root)
+}
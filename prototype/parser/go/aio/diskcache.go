@@ -0,0 +1,169 @@
+package aio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// FileCache is a Cache storing entries under Dir, one file per URL, so
+// they survive restarts of the process. Options.CacheDir creates one.
+type FileCache struct {
+	Dir string
+
+	// Compress stores bodies of more than a few hundred bytes
+	// gzip-compressed, trading some CPU on every hit for a much smaller
+	// cache on disk (Options.CompressCache)
+	Compress bool
+}
+
+// fileEntry is the on-disk record for one URL. Checksum covers Body so a
+// truncated or corrupted file is detected and treated as a miss.
+// Encoding "gzip" means Body is stored compressed; the checksum is always
+// of the decompressed bytes.
+type fileEntry struct {
+	CacheEntry
+	Checksum string `json:"sha256"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// path names the entry file for url
+func (c *FileCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the cached entry for url, or nil on a miss or an unreadable
+// entry, which is removed
+func (c *FileCache) Get(url string) *CacheEntry {
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	var e fileEntry
+	if json.Unmarshal(data, &e) != nil || e.URL != url || e.decode() != nil || e.Checksum != sha256Hex(string(e.Body)) {
+		os.Remove(c.path(url))
+		return nil
+	}
+	return &e.CacheEntry
+}
+
+// decode decompresses Body in place according to Encoding. Entries
+// written with and without compression can be read either way.
+func (e *fileEntry) decode() error {
+	switch e.Encoding {
+	case "":
+		return nil
+	case "gzip":
+		body, err := gunzipBody(e.Body)
+		if err != nil {
+			return err
+		}
+		e.Body, e.Encoding = body, ""
+		return nil
+	default:
+		return errors.New("unknown cache entry encoding " + e.Encoding)
+	}
+}
+
+// compressMinBytes is the smallest body the caches compress, below which
+// gzip's framing costs about as much as it saves
+const compressMinBytes = 512
+
+// gzipBody compresses body for a cache, or returns nil when it is shorter
+// than compressMinBytes
+func gzipBody(body []byte) []byte {
+	if len(body) < compressMinBytes {
+		return nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body)
+	if zw.Close() != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func gunzipBody(body []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// Put writes e to its file. Failures are ignored: the cache is an
+// optimization.
+func (c *FileCache) Put(e *CacheEntry) {
+	rec := fileEntry{CacheEntry: *e, Checksum: sha256Hex(string(e.Body))}
+	if c.Compress {
+		if zipped := gzipBody(e.Body); zipped != nil {
+			rec.Encoding, rec.Body = "gzip", zipped
+		}
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return
+	}
+	writeFileAtomic(c.path(e.URL), data)
+}
+
+// check reports whether entries can be written under the cache
+// directory, by creating and removing a probe file
+func (c *FileCache) check() error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, "probe.*.tmp")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and
+// renames it into place, so concurrent readers and writers only ever see
+// complete files
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// setConditionalHeaders asks the server to answer 304 if e is still current
+func setConditionalHeaders(req *http.Request, e *CacheEntry) {
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
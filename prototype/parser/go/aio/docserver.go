@@ -0,0 +1,301 @@
+package aio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of AIO documents
+const ContentType = "application/aio+json"
+
+// documentCacheControl is the Cache-Control the spec recommends for
+// published documents
+const documentCacheControl = "public, max-age=3600"
+
+// NewDocumentHandler serves one AIO document, as a reference for
+// publishers and a server to test agents against:
+//
+//	GET ai-content.aio             the document as published
+//	GET ai-content.aio?q=pricing   a document of the chunks matching q
+//	GET ai-content.aio?ids=a,b     a document of the named chunks
+//	GET ai-content.aio?since=t     a document of the chunks updated after t
+//
+// where t is an RFC 3339 time, or the ETag of the full document, which is
+// answered 304 while the document is unchanged. Chunks updated after t
+// are those whose updated time, or else their index entry's or the
+// document's, is later, and those with no time recorded at all.
+//
+// Subsets keep the document's top-level fields and the index entries of
+// their chunks, in document order, but not its signature, which does not
+// cover them. Every response carries an ETag and is answered 304 when it
+// matches If-None-Match; it is gzipped for clients that accept it, and
+// served uncompressed, with byte ranges, to those that send Range. Clients
+// whose Accept names CBORContentType get the document as CBOR. The handler
+// answers at whatever path it is mounted, and data may be packed in any
+// form Parse reads.
+func NewDocumentHandler(data []byte, modTime time.Time) (http.Handler, error) {
+	data, err := unpack(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	data = trimLeadingNoise(data)
+	a, _, err := decodeAIO(data, Options{})
+	if err != nil {
+		return nil, err
+	}
+	fields, err := topLevelFields(data)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Content []json.RawMessage `json:"content"`
+		Index   []json.RawMessage `json:"index"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	etag := `"` + sha256Hex(string(data))[:32] + `"`
+	return &documentHandler{doc: a, data: data, etag: etag, fields: fields, content: raw.Content, index: raw.Index, modTime: modTime}, nil
+}
+
+type documentHandler struct {
+	doc     *AIOFile
+	data    []byte
+	etag    string            // of data, as a full GET serves it uncompressed
+	fields  []jsonField       // top-level fields in document order
+	content []json.RawMessage // parallel to doc.Content
+	index   []json.RawMessage // parallel to doc.Index
+	modTime time.Time
+}
+
+// jsonField is one member of a JSON object, as written
+type jsonField struct {
+	name  string
+	value json.RawMessage
+}
+
+func (h *documentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body := h.data
+	params := r.URL.Query()
+	var keep func(Chunk, *IndexEntry) bool
+	if params.Has("q") || params.Has("ids") {
+		keep = queryFilter(params.Get("q"), Options{})
+		if params.Has("ids") {
+			keep = idFilter(params.Get("ids"))
+		}
+	}
+	if since := params.Get("since"); since != "" {
+		if etagMatches(strings.Replace(since, `-gzip"`, `"`, 1), h.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			keep = both(keep, h.updatedAfter(t))
+		}
+	}
+	if keep != nil {
+		subset, err := h.subset(keep)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = subset
+	}
+
+	contentType := ContentType
+	if acceptsCBOR(r) {
+		packed, err := jsonToCBOR(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body, contentType = packed, CBORContentType
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Cache-Control", documentCacheControl)
+	header.Set("Access-Control-Allow-Origin", "*")
+	header.Set("Vary", "Accept, Accept-Encoding")
+	if h.doc.Version != "" {
+		header.Set("X-AIO-Version", h.doc.Version)
+	}
+	etag := `"` + sha256Hex(string(body))[:32] + `"`
+
+	if !acceptsGzip(r) || r.Header.Get("Range") != "" {
+		header.Set("ETag", etag)
+		http.ServeContent(w, r, "", h.modTime, bytes.NewReader(body))
+		return
+	}
+
+	// The compressed representation has its own validator
+	etag = strings.TrimSuffix(etag, `"`) + `-gzip"`
+	header.Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body)
+	zw.Close()
+	header.Set("Content-Encoding", "gzip")
+	if !h.modTime.IsZero() {
+		header.Set("Last-Modified", h.modTime.UTC().Format(http.TimeFormat))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+// subset writes a document of the chunks keep accepts, with their index
+// entries
+func (h *documentHandler) subset(keep func(Chunk, *IndexEntry) bool) ([]byte, error) {
+	entries := indexByID(h.doc.Index)
+	kept := map[string]bool{}
+	content := []json.RawMessage{}
+	for i, c := range h.doc.Content {
+		if keep(c, entries[c.ID]) {
+			kept[c.ID] = true
+			content = append(content, h.content[i])
+		}
+	}
+	index := []json.RawMessage{}
+	for i, e := range h.doc.Index {
+		if kept[e.ID] {
+			index = append(index, h.index[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for _, f := range h.fields {
+		value := f.value
+		switch f.name {
+		case "signature":
+			continue
+		case "content":
+			value, _ = json.Marshal(content)
+		case "index":
+			value, _ = json.Marshal(index)
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		name, _ := json.Marshal(f.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// updatedAfter keeps the chunks changed after t, by their updated time
+// or the index entry's or document's, and those with none, which may have
+// changed at any time
+func (h *documentHandler) updatedAfter(t time.Time) func(Chunk, *IndexEntry) bool {
+	var docUpdated string
+	if h.doc.Metadata != nil {
+		docUpdated = h.doc.Metadata.LastUpdated
+	}
+	return func(c Chunk, entry *IndexEntry) bool {
+		updated, ok := chunkUpdated(c, entry, docUpdated)
+		return !ok || updated.After(t)
+	}
+}
+
+// both keeps the chunks a and b both keep; a nil filter keeps every chunk
+func both(a, b func(Chunk, *IndexEntry) bool) func(Chunk, *IndexEntry) bool {
+	if a == nil {
+		return b
+	}
+	return func(c Chunk, e *IndexEntry) bool { return a(c, e) && b(c, e) }
+}
+
+// idFilter keeps the chunks named in a comma-separated list
+func idFilter(list string) func(Chunk, *IndexEntry) bool {
+	ids := map[string]bool{}
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids[id] = true
+		}
+	}
+	return func(c Chunk, _ *IndexEntry) bool { return ids[c.ID] }
+}
+
+// topLevelFields lists the members of a JSON object in the order written
+func topLevelFields(data []byte) ([]jsonField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("aio: document is not a JSON object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{name: name, value: value})
+	}
+	return fields, nil
+}
+
+// acceptsCBOR reports whether the request's Accept asks for
+// CBORContentType
+func acceptsCBOR(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), CBORContentType) {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match list names etag, comparing
+// weakly as RFC 9110 requires for that header
+func etagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxDocuments bounds the child documents followed from an index
+// document when Options.MaxDocuments is zero
+const DefaultMaxDocuments = 10
+
+// DocumentRef lists one child document of an index document, which large
+// sites publish in place of a single ai-content.aio, one child per section
+type DocumentRef struct {
+	// URL locates the child document, relative to the index document
+	URL string `json:"url"`
+
+	// Title, Section, Keywords and Summary describe the child for choosing
+	// which ones a query needs
+	Title    string   `json:"title,omitempty"`
+	Section  string   `json:"section,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+}
+
+// selectDocuments picks the child documents a query needs, best match
+// first: those whose description matches a query term, or every one when
+// there is no query or nothing matches. At most limit are returned.
+func selectDocuments(refs []DocumentRef, query string, opts Options, limit int) []DocumentRef {
+	terms := queryTerms(query, opts)
+	weights := opts.FieldWeights.orDefault()
+	type scored struct {
+		ref   DocumentRef
+		score float64
+	}
+	var matched []scored
+	for _, ref := range refs {
+		// A child is scored as a chunk whose content is its summary
+		chunk := Chunk{Content: ref.Summary}
+		entry := &IndexEntry{Title: ref.Title + " " + ref.Section, Keywords: ref.Keywords}
+		if score, hits := scoreChunk(chunk, entry, chunkKeywords(entry), terms, weights); len(hits) > 0 {
+			matched = append(matched, scored{ref, score})
+		}
+	}
+
+	var selected []DocumentRef
+	if len(matched) == 0 {
+		selected = append(selected, refs...)
+	} else {
+		sort.SliceStable(matched, func(i, j int) bool { return matched[i].score > matched[j].score })
+		for _, m := range matched {
+			selected = append(selected, m.ref)
+		}
+	}
+	if len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+// documentResult summarizes the child documents followed from an index
+type documentResult struct {
+	verified bool        // every document's signature verified
+	failed   []PageError // children skipped under PartialResult
+	limitHit bool        // stopped early by MaxTotalBytes
+}
+
+// fetchedDocument is a child document downloaded and decoded
+type fetchedDocument struct {
+	aio      *AIOFile
+	verified bool
+}
+
+// follow returns the document merged with the child documents query
+// needs, when it is an index document, each chunk recording the URL of
+// the document it came from. Children that are indexes themselves are
+// followed in turn, sharing the same bound. A chunk ID already taken by an
+// earlier document gets a numeric suffix. Children are downloaded once
+// however many queries the document answers.
+func (d *document) follow(query string) (*AIOFile, documentResult, error) {
+	result := documentResult{verified: d.verified}
+	if len(d.aio.Documents) == 0 || d.sess == nil {
+		return d.aio, result, nil
+	}
+	s := d.sess
+	opts := s.opts
+	limit := opts.MaxDocuments
+	if limit == 0 {
+		limit = DefaultMaxDocuments
+	}
+	if limit < 0 {
+		return d.aio, result, nil
+	}
+
+	indexURL := d.src.url
+	merged := *d.aio
+	merged.Content = append([]Chunk(nil), d.aio.Content...)
+	merged.Index = append([]IndexEntry(nil), d.aio.Index...)
+	ids := map[string]bool{}
+	for i := range merged.Content {
+		ids[merged.Content[i].ID] = true
+		merged.Content[i].SourceURL = indexURL
+	}
+
+	type pending struct {
+		ref  DocumentRef
+		base string
+	}
+	var queue []pending
+	for _, ref := range selectDocuments(d.aio.Documents, query, opts, limit) {
+		queue = append(queue, pending{ref, indexURL})
+	}
+	seen := map[string]bool{indexURL: true}
+	followed := 0
+	for len(queue) > 0 && followed < limit {
+		next := queue[0]
+		queue = queue[1:]
+		docURL, err := resolveReference(next.base, next.ref.URL)
+		if err != nil {
+			return nil, result, fmt.Errorf("document %q: bad url: %w", next.ref.URL, err)
+		}
+		if seen[docURL] {
+			continue
+		}
+		seen[docURL] = true
+
+		child, ok := d.children[docURL]
+		if !ok {
+			byteLimit, total, exhausted := downloadLimit(s)
+			if exhausted {
+				result.limitHit = true
+				break
+			}
+			aio, verified, err := fetchPage(s, docURL, byteLimit)
+			if total && errors.Is(err, ErrTooLarge) {
+				result.limitHit = true
+				break
+			}
+			if err != nil {
+				if !opts.PartialResult {
+					return nil, result, fmt.Errorf("document %s: %w", docURL, err)
+				}
+				result.failed = append(result.failed, PageError{URL: docURL, Error: err.Error()})
+				continue
+			}
+			child = &fetchedDocument{aio: aio, verified: verified}
+			if d.children == nil {
+				d.children = map[string]*fetchedDocument{}
+			}
+			d.children[docURL] = child
+			s.run.progress.DocumentsFollowed++
+			s.run.report(PhaseDocument)
+		}
+		followed++
+
+		renamed := map[string]string{}
+		for _, c := range child.aio.Content {
+			if id := uniqueID(ids, c.ID); id != c.ID {
+				renamed[c.ID] = id
+				c.ID = id
+			}
+			c.SourceURL = docURL
+			merged.Content = append(merged.Content, c)
+		}
+		for _, e := range child.aio.Index {
+			if id, ok := renamed[e.ID]; ok {
+				e.ID = id
+			}
+			merged.Index = append(merged.Index, e)
+		}
+		result.verified = result.verified && child.verified
+		for _, ref := range selectDocuments(child.aio.Documents, query, opts, limit) {
+			queue = append(queue, pending{ref, docURL})
+		}
+	}
+	return &merged, result, nil
+}
@@ -0,0 +1,288 @@
+package aio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+)
+
+// Retriever scores the chunks of a document against a query in place of
+// the keyword rankings of Options.Ranking. Score returns one score per
+// chunk of doc.Content, in order; chunks scoring above zero match. A
+// Retriever returns ErrNoEmbeddings when it cannot score the document,
+// and the parse falls back to keyword ranking.
+type Retriever interface {
+	Score(ctx context.Context, query string, doc *AIOFile) ([]float64, error)
+}
+
+// RetrieverFunc adapts a function to Retriever
+type RetrieverFunc func(ctx context.Context, query string, doc *AIOFile) ([]float64, error)
+
+func (f RetrieverFunc) Score(ctx context.Context, query string, doc *AIOFile) ([]float64, error) {
+	return f(ctx, query, doc)
+}
+
+// ErrNoEmbeddings is returned by a Retriever with no vectors for a
+// document: it ships none the retriever can use and there is no Embedder
+// to compute them
+var ErrNoEmbeddings = errors.New("aio: no embeddings available")
+
+// Embedder turns texts into vectors, one per text in order, e.g. a local
+// model or an embeddings API
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedderFunc adapts a function to Embedder
+type EmbedderFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+func (f EmbedderFunc) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return f(ctx, texts)
+}
+
+// EmbeddingModel names the model a document's precomputed chunk
+// embeddings were made with, so queries are embedded with the same one
+type EmbeddingModel struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions,omitempty"`
+}
+
+// DefaultMinSimilarity is the cosine similarity a chunk needs to match
+// when EmbeddingRetriever.MinSimilarity is zero
+const DefaultMinSimilarity = 0.3
+
+// EmbeddingRetriever ranks chunks by the cosine similarity of their
+// embeddings to the query's, so a query for "cost" finds a chunk about
+// pricing. Chunks use the vectors the document ships when they were made
+// with Model; otherwise their index title and content are embedded with
+// Embedder, and the vectors kept for later parses. It is safe for
+// concurrent use.
+type EmbeddingRetriever struct {
+	// Embedder embeds the query, and the chunks of documents without
+	// usable precomputed embeddings. Without one, no query can be
+	// embedded and every document falls back to keyword ranking.
+	Embedder Embedder
+
+	// Model is the model Embedder produces. Precomputed embeddings are
+	// used when the document names the same model, or either is empty
+	// and the dimensions agree.
+	Model string
+
+	// MinSimilarity is the similarity a chunk needs to match; zero uses
+	// DefaultMinSimilarity
+	MinSimilarity float64
+
+	mu      sync.Mutex
+	vectors map[string][]float32 // computed chunk vectors by text hash
+}
+
+func (r *EmbeddingRetriever) Score(ctx context.Context, query string, doc *AIOFile) ([]float64, error) {
+	if r.Embedder == nil {
+		return nil, ErrNoEmbeddings
+	}
+	q, err := r.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embedding the query: %w", err)
+	}
+	if len(q) != 1 {
+		return nil, fmt.Errorf("embedding the query: got %d vectors for 1 text", len(q))
+	}
+	vectors, err := r.chunkVectors(ctx, doc, len(q[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	min := r.MinSimilarity
+	if min == 0 {
+		min = DefaultMinSimilarity
+	}
+	scores := make([]float64, len(vectors))
+	for i, v := range vectors {
+		if sim := cosine(q[0], v); sim >= min {
+			scores[i] = sim
+		}
+	}
+	return scores, nil
+}
+
+// chunkVectors returns a vector of dims dimensions for each chunk of doc:
+// the ones it ships when they fit, and computed ones otherwise
+func (r *EmbeddingRetriever) chunkVectors(ctx context.Context, doc *AIOFile, dims int) ([][]float32, error) {
+	if r.shipped(doc, dims) {
+		vectors := make([][]float32, len(doc.Content))
+		for i, c := range doc.Content {
+			vectors[i] = c.Embedding
+		}
+		return vectors, nil
+	}
+
+	entries := indexByID(doc.Index)
+	keys := make([]string, len(doc.Content))
+	var missing []string
+	var missingKeys []string
+	r.mu.Lock()
+	for i, c := range doc.Content {
+		text := c.Content
+		if e := entries[c.ID]; e != nil && e.Title != "" {
+			text = e.Title + "\n\n" + text
+		}
+		keys[i] = sha256Hex(text)
+		if _, ok := r.vectors[keys[i]]; !ok {
+			missing = append(missing, text)
+			missingKeys = append(missingKeys, keys[i])
+		}
+	}
+	r.mu.Unlock()
+
+	if len(missing) > 0 {
+		computed, err := r.Embedder.Embed(ctx, missing)
+		if err != nil {
+			return nil, fmt.Errorf("embedding chunks: %w", err)
+		}
+		if len(computed) != len(missing) {
+			return nil, fmt.Errorf("embedding chunks: got %d vectors for %d texts", len(computed), len(missing))
+		}
+		r.mu.Lock()
+		if r.vectors == nil {
+			r.vectors = map[string][]float32{}
+		}
+		for i, v := range computed {
+			r.vectors[missingKeys[i]] = v
+		}
+		r.mu.Unlock()
+	}
+
+	vectors := make([][]float32, len(keys))
+	r.mu.Lock()
+	for i, k := range keys {
+		vectors[i] = r.vectors[k]
+	}
+	r.mu.Unlock()
+	return vectors, nil
+}
+
+// shipped reports whether every chunk of doc carries an embedding of dims
+// dimensions made with the retriever's model
+func (r *EmbeddingRetriever) shipped(doc *AIOFile, dims int) bool {
+	if doc.Embeddings != nil && doc.Embeddings.Model != "" && r.Model != "" && doc.Embeddings.Model != r.Model {
+		return false
+	}
+	for _, c := range doc.Content {
+		if len(c.Embedding) != dims {
+			return false
+		}
+	}
+	return len(doc.Content) > 0
+}
+
+// cosine is the cosine similarity of two vectors, zero when their lengths
+// differ or either is all zeros
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// DefaultEmbeddingsURL is the endpoint of an HTTPEmbedder with no URL
+const DefaultEmbeddingsURL = "https://api.openai.com/v1/embeddings"
+
+// embedBatchSize bounds the texts sent in one embeddings request
+const embedBatchSize = 100
+
+// HTTPEmbedder embeds texts through an OpenAI-compatible embeddings API,
+// which OpenAI serves and local model servers such as Ollama, llama.cpp
+// and vLLM also offer
+type HTTPEmbedder struct {
+	// URL is the embeddings endpoint; empty uses DefaultEmbeddingsURL
+	URL string
+
+	// Model is sent with each request, e.g. "text-embedding-3-small"
+	Model string
+
+	// APIKey, when set, is sent as a bearer token
+	APIKey string
+
+	// Client performs the requests; nil uses http.DefaultClient
+	Client *http.Client
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch, err := e.embedBatch(ctx, texts[start:min(start+embedBatchSize, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *HTTPEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	url := e.URL
+	if url == "" {
+		url = DefaultEmbeddingsURL
+	}
+	body, err := json.Marshal(map[string]any{"model": e.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var decoded struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("POST %s: %w", url, err)
+	}
+	if len(decoded.Data) != len(texts) {
+		return nil, fmt.Errorf("POST %s: got %d embeddings for %d texts", url, len(decoded.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range decoded.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("POST %s: embedding index %d out of range", url, d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
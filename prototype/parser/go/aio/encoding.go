@@ -0,0 +1,169 @@
+package aio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContentDecoder undoes one compression scheme, reading the compressed
+// bytes from r
+type ContentDecoder func(r io.Reader) (io.ReadCloser, error)
+
+// ErrUnsupportedEncoding is returned for a response or document
+// compressed with a scheme no ContentDecoder is registered for
+var ErrUnsupportedEncoding = errors.New("aio: unsupported content encoding")
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = map[string]ContentDecoder{
+		"gzip":    gzipDecoder,
+		"x-gzip":  gzipDecoder,
+		"deflate": deflateDecoder,
+	}
+)
+
+func gzipDecoder(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+
+// deflateDecoder reads HTTP's "deflate", which is zlib-wrapped
+func deflateDecoder(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) }
+
+// RegisterContentEncoding makes the HTTP fetcher accept and decode the
+// content coding named, e.g. "br" or "zstd", replacing any decoder
+// registered for it before; a nil d removes it. The standard library has
+// neither, so they are registered from a package of the caller's choosing:
+//
+//	aio.RegisterContentEncoding("br", func(r io.Reader) (io.ReadCloser, error) {
+//		return io.NopCloser(brotli.NewReader(r)), nil
+//	})
+//
+// A decoder registered as "zstd" also unpacks zstd-compressed documents
+// read from files and loaders. gzip and deflate are built in.
+func RegisterContentEncoding(coding string, d ContentDecoder) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	coding = strings.ToLower(coding)
+	if d == nil {
+		delete(encodings, coding)
+		return
+	}
+	encodings[coding] = d
+}
+
+func decoderFor(coding string) ContentDecoder {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	return encodings[strings.ToLower(coding)]
+}
+
+// acceptEncoding is the Accept-Encoding header offering every registered
+// coding, gzip and deflate first
+func acceptEncoding() string {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	var codings []string
+	for _, c := range []string{"gzip", "deflate"} {
+		if encodings[c] != nil {
+			codings = append(codings, c)
+		}
+	}
+	var others []string
+	for c := range encodings {
+		if c != "gzip" && c != "deflate" && c != "x-gzip" {
+			others = append(others, c)
+		}
+	}
+	sort.Strings(others)
+	return strings.Join(append(codings, others...), ", ")
+}
+
+// decodeBody undoes the Content-Encoding of a response body, returning
+// the body and a copy of header describing it as decoded. Codings are
+// listed in the order they were applied. The decoded body is bounded by
+// limit as the download was; zero or less means no limit.
+func decodeBody(body []byte, header http.Header, limit int64) ([]byte, http.Header, error) {
+	coding := header.Get("Content-Encoding")
+	if coding == "" {
+		return body, header, nil
+	}
+	codings := strings.Split(coding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		c := strings.TrimSpace(codings[i])
+		if c == "" || strings.EqualFold(c, "identity") {
+			continue
+		}
+		var err error
+		if body, err = decompress(body, c, limit); err != nil {
+			return nil, nil, err
+		}
+	}
+	header = header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return body, header, nil
+}
+
+// decompress decodes data with the decoder registered for coding
+func decompress(data []byte, coding string, limit int64) ([]byte, error) {
+	d := decoderFor(coding)
+	if d == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedEncoding, coding)
+	}
+	zr, err := d(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", coding, err)
+	}
+	defer zr.Close()
+	var buf bytes.Buffer
+	if err := copyLimited(&buf, zr, limit); err != nil {
+		if !errors.Is(err, ErrTooLarge) {
+			err = fmt.Errorf("decoding %s: %w", coding, err)
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Magic numbers of the packed forms a document may take
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// maxPackingLayers bounds how many compression layers unpack peels off
+const maxPackingLayers = 3
+
+// unpack turns a document in any of its packed forms into JSON: gzip or
+// zstd compression, as static .aio.gz and .aio.zst files are served, is
+// undone, and a CBOR document (application/aio+cbor) is converted.
+// Documents already JSON are returned as they are. The unpacked document
+// is bounded by limit; zero or less means no limit.
+func unpack(data []byte, limit int64) ([]byte, error) {
+	for layer := 0; ; layer++ {
+		var coding string
+		switch {
+		case bytes.HasPrefix(data, gzipMagic):
+			coding = "gzip"
+		case bytes.HasPrefix(data, zstdMagic):
+			coding = "zstd"
+		case isCBOR(data):
+			return cborToJSON(data)
+		default:
+			return data, nil
+		}
+		if layer == maxPackingLayers {
+			return nil, fmt.Errorf("%w: more than %d compression layers", ErrMalformed, maxPackingLayers)
+		}
+		var err error
+		if data, err = decompress(data, coding, limit); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package aio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Exporter writes the chunks of an envelope to a store a RAG pipeline
+// retrieves from, such as a full-text index or a vector database
+// (ExportEnvelope). Records carry IDs that are stable across parses of
+// the same document, so exporting again replaces rather than duplicates.
+type Exporter interface {
+	Export(ctx context.Context, records []ExportRecord) error
+}
+
+// ExporterFunc adapts a function to Exporter
+type ExporterFunc func(ctx context.Context, records []ExportRecord) error
+
+func (f ExporterFunc) Export(ctx context.Context, records []ExportRecord) error {
+	return f(ctx, records)
+}
+
+// ExportRecord is one chunk as exported
+type ExportRecord struct {
+	// ID is unique across documents: the document's URL and the chunk ID,
+	// as in "https://example.com/.well-known/aio.json#pricing"
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	Hash string `json:"hash,omitempty"`
+
+	// Metadata holds what a store filters and cites by: "chunk_id",
+	// "document_url", "source_url", "hash", and whichever of "title",
+	// "section", "content_type", "category", "language", "tags",
+	// "keywords", "last_updated" and "license" are known
+	Metadata map[string]any `json:"metadata"`
+
+	// Embedding is the vector of Text, when ExportEnvelope was given an
+	// Embedder
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// ExportRecords converts the chunks of env to records, in order. Parse
+// with Options.InlineKeywords to export the index keywords too.
+func ExportRecords(env *ContentEnvelope) []ExportRecord {
+	document := env.DocumentURL
+	if document == "" {
+		document = env.SourceURL
+	}
+	var meta Metadata
+	if env.Metadata != nil {
+		meta = *env.Metadata
+	}
+	records := make([]ExportRecord, len(env.Items))
+	for i, c := range env.Items {
+		source, title := env.chunkSource(c), ""
+		if c.Provenance != nil {
+			source, title = c.Provenance.URL, c.Provenance.Title
+		}
+		m := map[string]any{"chunk_id": c.ID, "document_url": document, "source_url": source}
+		set := func(key, value string) {
+			if value != "" {
+				m[key] = value
+			}
+		}
+		set("hash", c.Hash)
+		set("title", title)
+		set("section", c.Section)
+		set("content_type", c.ContentType)
+		set("category", c.Category)
+		set("language", firstNonEmpty(c.Language, c.DetectedLanguage, meta.Language))
+		set("last_updated", firstNonEmpty(c.Updated, meta.LastUpdated))
+		set("license", meta.License)
+		if len(c.Tags) > 0 {
+			m["tags"] = c.Tags
+		}
+		if len(c.Keywords) > 0 {
+			m["keywords"] = c.Keywords
+		}
+		records[i] = ExportRecord{ID: document + "#" + c.ID, Text: c.Content, Hash: c.Hash, Metadata: m}
+	}
+	return records
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ExportEnvelope exports the chunks of env to exp: those the query
+// selected, or all of them for a parse without one. With an embedder,
+// each record's text is embedded first.
+func ExportEnvelope(ctx context.Context, env *ContentEnvelope, exp Exporter, embedder Embedder) error {
+	records := ExportRecords(env)
+	if embedder != nil && len(records) > 0 {
+		texts := make([]string, len(records))
+		for i, r := range records {
+			texts[i] = r.Text
+		}
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embedding: %w", err)
+		}
+		if len(vectors) != len(records) {
+			return fmt.Errorf("embedding: got %d vectors for %d chunks", len(vectors), len(records))
+		}
+		for i := range records {
+			records[i].Embedding = vectors[i]
+		}
+	}
+	return exp.Export(ctx, records)
+}
+
+// JSONLLayout is the shape of a JSONLExporter line
+type JSONLLayout string
+
+const (
+	// LayoutLlamaIndex writes each record as a LlamaIndex TextNode:
+	// "id_", "text", "metadata" and "embedding"
+	LayoutLlamaIndex JSONLLayout = "llamaindex"
+
+	// LayoutLangChain writes each record as a LangChain Document: "id",
+	// "page_content" and "metadata", with no embedding
+	LayoutLangChain JSONLLayout = "langchain"
+)
+
+// JSONLExporter writes one record per line as JSON
+type JSONLExporter struct {
+	W io.Writer
+
+	// Layout is the shape of each line; empty uses LayoutLlamaIndex
+	Layout JSONLLayout
+}
+
+func (e *JSONLExporter) Export(ctx context.Context, records []ExportRecord) error {
+	w := bufio.NewWriter(e.W)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, r := range records {
+		var line any
+		switch e.Layout {
+		case "", LayoutLlamaIndex:
+			line = struct {
+				ID        string         `json:"id_"`
+				Text      string         `json:"text"`
+				Metadata  map[string]any `json:"metadata"`
+				Embedding []float32      `json:"embedding,omitempty"`
+			}{r.ID, r.Text, r.Metadata, r.Embedding}
+		case LayoutLangChain:
+			line = struct {
+				ID          string         `json:"id"`
+				PageContent string         `json:"page_content"`
+				Metadata    map[string]any `json:"metadata"`
+			}{r.ID, r.Text, r.Metadata}
+		default:
+			return fmt.Errorf("unknown JSONL layout %q (want llamaindex or langchain)", e.Layout)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// DefaultExportTable names the tables of a SQLiteExporter with no Table
+const DefaultExportTable = "aio_chunks"
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLiteExporter writes records as a SQLite script, to be loaded with
+//
+//	sqlite3 chunks.db < chunks.sql
+//
+// since the parser links no database driver. The script creates, unless
+// they exist, a table of the records keyed by ID, with metadata and
+// embedding as JSON text, and an FTS5 table beside it named with an _fts
+// suffix for full-text search:
+//
+//	SELECT c.* FROM aio_chunks_fts f JOIN aio_chunks c ON c.id = f.id
+//	WHERE aio_chunks_fts MATCH 'pricing' ORDER BY rank
+//
+// Records already present are replaced, all in one transaction.
+type SQLiteExporter struct {
+	W io.Writer
+
+	// Table names the records table; empty uses DefaultExportTable
+	Table string
+}
+
+func (e *SQLiteExporter) Export(ctx context.Context, records []ExportRecord) error {
+	table := e.Table
+	if table == "" {
+		table = DefaultExportTable
+	}
+	if !sqlIdentifier.MatchString(table) {
+		return fmt.Errorf("invalid table name %q", table)
+	}
+	w := bufio.NewWriter(e.W)
+	fmt.Fprintf(w, "BEGIN;\n")
+	fmt.Fprintf(w, "CREATE TABLE IF NOT EXISTS %s (id TEXT PRIMARY KEY, text TEXT NOT NULL, hash TEXT, metadata TEXT, embedding TEXT);\n", table)
+	fmt.Fprintf(w, "CREATE VIRTUAL TABLE IF NOT EXISTS %s_fts USING fts5(id UNINDEXED, text);\n", table)
+	for _, r := range records {
+		meta, err := json.Marshal(r.Metadata)
+		if err != nil {
+			return err
+		}
+		embedding := "NULL"
+		if r.Embedding != nil {
+			v, err := json.Marshal(r.Embedding)
+			if err != nil {
+				return err
+			}
+			embedding = sqlQuote(string(v))
+		}
+		id, text := sqlQuote(r.ID), sqlQuote(r.Text)
+		fmt.Fprintf(w, "INSERT OR REPLACE INTO %s (id, text, hash, metadata, embedding) VALUES (%s, %s, %s, %s, %s);\n",
+			table, id, text, sqlQuote(r.Hash), sqlQuote(string(meta)), embedding)
+		fmt.Fprintf(w, "DELETE FROM %s_fts WHERE id = %s;\n", table, id)
+		fmt.Fprintf(w, "INSERT INTO %s_fts (id, text) VALUES (%s, %s);\n", table, id, text)
+	}
+	fmt.Fprintf(w, "COMMIT;\n")
+	return w.Flush()
+}
+
+// sqlQuote writes s as a SQL string literal. A NUL would end the literal
+// early in the sqlite3 shell, so it is dropped.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, "\x00", ""), "'", "''") + "'"
+}
+
+// DefaultExportBatchSize is the records an HTTPExporter with no BatchSize
+// sends in one request
+const DefaultExportBatchSize = 100
+
+// HTTPExporter upserts records through a bulk endpoint, POSTing them in
+// batches as
+//
+//	{"records": [{"id": ..., "text": ..., "hash": ..., "metadata": {...}, "embedding": [...]}]}
+//
+// for a small service, or a vector database's ingest proxy, to store by
+// ID. Any response other than 2xx fails the export; earlier batches stay
+// stored.
+type HTTPExporter struct {
+	URL string
+
+	// Token, when set, is sent as a bearer token, and Header is added to
+	// each request
+	Token  string
+	Header http.Header
+
+	// BatchSize caps the records of one request; zero uses
+	// DefaultExportBatchSize
+	BatchSize int
+
+	// Client performs the requests; nil uses http.DefaultClient
+	Client *http.Client
+}
+
+func (e *HTTPExporter) Export(ctx context.Context, records []ExportRecord) error {
+	size := e.BatchSize
+	if size <= 0 {
+		size = DefaultExportBatchSize
+	}
+	for start := 0; start < len(records); start += size {
+		if err := e.post(ctx, records[start:min(start+size, len(records))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *HTTPExporter) post(ctx context.Context, records []ExportRecord) error {
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", e.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
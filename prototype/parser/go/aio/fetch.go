@@ -0,0 +1,369 @@
+package aio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fetch downloads url exactly as served, for callers that archive or
+// re-process documents themselves
+func Fetch(url string) ([]byte, http.Header, error) {
+	return FetchWithOptions(url, Options{})
+}
+
+// FetchWithOptions is Fetch with explicit transport options; MaxBytes,
+// credentials, headers and the cache apply as they do for Parse
+func FetchWithOptions(url string, opts Options) ([]byte, http.Header, error) {
+	url, urlAuth := stripCredentials(url)
+	sess, err := newSession(context.Background(), opts, nil, url, urlAuth, newParseRun(opts))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer sess.close()
+	return sess.fetchLimited(url, opts.MaxBytes)
+}
+
+// Transport defaults applied when the corresponding Options are zero
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxRedirects = 5
+	DefaultUserAgent    = "AIOParser-Go/0.1 (ECR-Compatible)"
+)
+
+// newHTTPClient builds the client for a parse from the transport options
+func newHTTPClient(opts Options) *http.Client {
+	timeout := opts.Timeout
+	switch {
+	case timeout == 0:
+		timeout = DefaultTimeout
+	case timeout < 0:
+		timeout = 0
+	}
+	maxRedirects := opts.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	return &http.Client{
+		Jar:     opts.CookieJar,
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if maxRedirects < 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// ErrTooLarge is returned when a document exceeds Options.MaxBytes,
+// MaxChunks or MaxChunkBytes
+var ErrTooLarge = errors.New("aio: response exceeds size limit")
+
+// session carries the HTTP state shared by every request of one Parse
+// call: the client (and its cookie jar), the request headers and the
+// context bounding them
+type session struct {
+	ctx    context.Context
+	client *http.Client
+	header http.Header
+	opts   Options
+	run    *parseRun
+	cache  Cache
+
+	// guard keeps requests off private networks; preflight is set when
+	// the client is the caller's, so the guard checks each request before
+	// it is sent rather than at dial time
+	guard     *netGuard
+	preflight bool
+
+	// transport is the guarded transport the session built, if any,
+	// whose idle connections close releases
+	transport *http.Transport
+
+	// originHost is the host and port of the URL passed to Parse, the
+	// only one sent originAuth (originAuthorization)
+	originHost string
+	originAuth string
+
+	// robotsFiles holds each origin's robots.txt once fetched
+	robotsMu    sync.Mutex
+	robotsFiles map[string]*robotsFile
+}
+
+// newSession prepares the client for a Parse call of origin, the URL
+// with any userinfo stripped into urlAuth. Cookies set by any response,
+// such as a session cookie issued during discovery, are sent with the
+// later requests when a jar is configured. A non-nil client is used in
+// place of one built from opts.
+func newSession(ctx context.Context, opts Options, client *http.Client, origin, urlAuth string, run *parseRun) (*session, error) {
+	guard := newNetGuard(opts)
+	if opts.TrustParseHost {
+		guard.trust(origin)
+	}
+	preflight := !opts.AllowPrivateNetworks
+	var transport *http.Transport
+	if client == nil {
+		client = newHTTPClient(opts)
+		if !opts.AllowPrivateNetworks {
+			transport = guardedTransport(guard)
+			client.Transport = transport
+			preflight = false
+		}
+	}
+	if preflight {
+		// The caller's client follows redirects itself, so each hop is
+		// checked like the first request, on a copy as the jar is
+		c := *client
+		c.CheckRedirect = guardRedirects(guard, client.CheckRedirect)
+		client = &c
+	}
+	if client.Jar == nil && opts.Cookies {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		// A caller's client is shared with their other requests and
+		// parses, so the jar goes on a copy
+		c := *client
+		c.Jar = jar
+		client = &c
+	}
+	return &session{
+		ctx:    ctx,
+		client: client,
+		header: requestHeader(opts),
+		opts:   opts,
+		run:    run,
+		cache:  cacheFor(opts),
+
+		guard:     guard,
+		preflight: preflight,
+		transport: transport,
+
+		originHost: hostPort(origin),
+		originAuth: originAuthorization(opts, urlAuth),
+	}, nil
+}
+
+// guardRedirects is a CheckRedirect that checks the target of each
+// redirect with g before deferring to next, or to the default limit of
+// ten redirects when next is nil
+func guardRedirects(g *netGuard, next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if err := g.check(req.Context(), req); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+// close releases the connections the session's own transport keeps
+// idle. The session is not used afterwards.
+func (s *session) close() {
+	if s.transport != nil {
+		s.transport.CloseIdleConnections()
+	}
+}
+
+// fetch downloads url into memory. When the body is cut off mid-transfer
+// it re-requests the remainder with a Range header, up to
+// opts.ResumeAttempts times, falling back to a full refetch when the
+// server does not honour byte ranges.
+func (s *session) fetch(url string) ([]byte, error) {
+	body, _, err := s.fetchLimited(url, s.opts.MaxBytes)
+	return body, err
+}
+
+// fetchLimited is fetch with an explicit size limit in place of
+// Options.MaxBytes, also returning the response headers
+func (s *session) fetchLimited(url string, limit int64) ([]byte, http.Header, error) {
+	clean, _ := stripCredentials(url)
+	span := s.run.obs.start(s.ctx, StageFetch, slog.String("url", clean))
+	ev := FetchEvent{URL: clean, Cache: CacheMiss}
+	started := time.Now()
+	body, header, err := s.fetchBody(url, limit, &ev)
+	ev.Bytes, ev.Duration, ev.Err = int64(len(body)), time.Since(started), err
+	s.run.obs.fetch(ev)
+	span.End(err, slog.Int("status", ev.Status), slog.Int64("bytes", ev.Bytes), slog.String("cache", string(ev.Cache)))
+	return body, header, err
+}
+
+// fetchBody does the work of fetchLimited, recording in ev the status of
+// the last response and how the cache was used
+func (s *session) fetchBody(url string, limit int64, ev *FetchEvent) ([]byte, http.Header, error) {
+	opts := s.opts
+	if r := s.robots(url); !r.Allowed {
+		return nil, nil, fmt.Errorf("GET %s: %w: %s", url, ErrDisallowed, r.Reason)
+	}
+	req, err := newEncodedRequest(s.ctx, url, s.header)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := normalizeURL(url)
+	var cached *CacheEntry
+	if s.cache != nil && !opts.ForceRefresh {
+		cached = s.cache.Get(key)
+	}
+	if cached != nil && !cached.matches(req.Header) {
+		cached = nil
+	}
+	if cached != nil && cached.fresh(time.Now()) {
+		ev.Cache = CacheHit
+		s.run.report(PhaseFetch)
+		return cached.Body, cached.Header, nil
+	}
+	if cached != nil {
+		setConditionalHeaders(req, cached)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	ev.Status = resp.StatusCode
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		ev.Cache = CacheRevalidated
+		resp.Body.Close()
+		s.run.report(PhaseFetch)
+		cached = cached.revalidated(resp.Header, time.Now())
+		s.cache.Put(cached)
+		return cached.Body, cached.Header, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, statusError(url, resp)
+	}
+
+	// If-Range makes the server send the whole document again if it
+	// changed between attempts, so we never splice two versions together.
+	validator := resp.Header.Get("ETag")
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	ranges := resp.Header.Get("Accept-Ranges") == "bytes" && validator != ""
+
+	var buf bytes.Buffer
+	for attempt := 0; ; attempt++ {
+		err := copyLimited(&buf, resp.Body, limit)
+		resp.Body.Close()
+		if errors.Is(err, ErrTooLarge) {
+			return nil, nil, fmt.Errorf("GET %s: %w", url, err)
+		}
+		if err == nil {
+			s.run.progress.BytesDownloaded += int64(buf.Len())
+			body, header, err := decodeBody(buf.Bytes(), resp.Header, limit)
+			if err != nil {
+				return nil, nil, fmt.Errorf("GET %s: %w", url, err)
+			}
+			s.run.report(PhaseFetch)
+			if s.cache != nil {
+				if e := newCacheEntry(key, req, header, body, time.Now()); e != nil {
+					s.cache.Put(e)
+				}
+			}
+			return body, header, nil
+		}
+		if attempt >= opts.ResumeAttempts {
+			return nil, nil, err
+		}
+
+		req, reqErr := newEncodedRequest(s.ctx, url, s.header)
+		if reqErr != nil {
+			return nil, nil, reqErr
+		}
+		if ranges {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", buf.Len()))
+			req.Header.Set("If-Range", validator)
+		}
+
+		resp, err = s.do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		ev.Status = resp.StatusCode
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && rangeStart(resp) == int64(buf.Len()):
+			// Append the remainder to what we already have
+		case resp.StatusCode == http.StatusOK:
+			buf.Reset()
+		default:
+			resp.Body.Close()
+			return nil, nil, fmt.Errorf("resuming %s: %s", url, resp.Status)
+		}
+	}
+}
+
+// copyLimited appends r to buf, failing with ErrTooLarge once buf would
+// grow past limit bytes. A limit of zero or less means no limit.
+func copyLimited(buf *bytes.Buffer, r io.Reader, limit int64) error {
+	if limit <= 0 {
+		_, err := io.Copy(buf, r)
+		return err
+	}
+	remaining := limit - int64(buf.Len())
+	n, err := io.Copy(buf, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		return ErrTooLarge
+	}
+	return err
+}
+
+// newRequest builds a GET for url carrying the Parse call's headers
+func newRequest(ctx context.Context, url string, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+// newEncodedRequest is newRequest offering every registered content
+// coding, unless the caller set an Accept-Encoding of their own. Naming
+// codings turns off the transport's transparent gzip, so the response is
+// decoded with decodeBody.
+func newEncodedRequest(ctx context.Context, url string, header http.Header) (*http.Request, error) {
+	req, err := newRequest(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+	return req, nil
+}
+
+// rangeStart returns the first byte offset of a 206 response, or -1
+func rangeStart(resp *http.Response) int64 {
+	// Content-Range: bytes 1024-2047/4096
+	spec, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, _ := strings.Cut(spec, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
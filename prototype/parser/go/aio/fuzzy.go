@@ -0,0 +1,102 @@
+package aio
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSuggestions caps the "did you mean" list on the envelope
+const maxSuggestions = 5
+
+// levenshtein returns the edit distance between a and b in runes
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// fuzzyThreshold is the largest edit distance still treated as a likely
+// typo of term: one edit per three runes, and at least one
+func fuzzyThreshold(term string) int {
+	return max(1, len([]rune(term))/3)
+}
+
+// maxFuzzyRunes is the longest query term suggestKeywords looks for typos
+// of. The edit distance costs the product of the two lengths, and a word
+// that long is pasted rather than mistyped.
+const maxFuzzyRunes = 64
+
+// suggestKeywords returns the document keywords closest to the query
+// terms, nearest first, for reporting when a query matched nothing
+func suggestKeywords(aio *AIOFile, terms []string) []string {
+	type fuzzyTerm struct {
+		text         string
+		runes, limit int
+	}
+	var fuzzy []fuzzyTerm
+	for _, term := range terms {
+		if n := utf8.RuneCountInString(term); n <= maxFuzzyRunes {
+			fuzzy = append(fuzzy, fuzzyTerm{term, n, fuzzyThreshold(term)})
+		}
+	}
+
+	best := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, idx := range aio.Index {
+		for _, k := range idx.Keywords {
+			keyword := strings.ToLower(k)
+			if seen[keyword] {
+				continue
+			}
+			seen[keyword] = true
+			n := utf8.RuneCountInString(keyword)
+			for _, term := range fuzzy {
+				// The distance is at least the difference in length, so
+				// keywords far longer or shorter need no comparing
+				if n-term.runes > term.limit || term.runes-n > term.limit {
+					continue
+				}
+				d := levenshtein(term.text, keyword)
+				if d == 0 || d > term.limit {
+					continue
+				}
+				if prev, ok := best[keyword]; !ok || d < prev {
+					best[keyword] = d
+				}
+			}
+		}
+	}
+
+	suggestions := make([]string, 0, len(best))
+	for keyword := range best {
+		suggestions = append(suggestions, keyword)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if best[a] != best[b] {
+			return best[a] < best[b]
+		}
+		return a < b
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
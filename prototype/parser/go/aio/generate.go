@@ -0,0 +1,357 @@
+package aio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// contentSchemaURL is the $schema of the documents Generate writes
+const contentSchemaURL = "https://aio-standard.org/schema/v2.1/content.json"
+
+// DefaultMaxKeywords is how many keywords Generate gives an index entry
+// when GenerateOptions.MaxKeywords is zero
+const DefaultMaxKeywords = 10
+
+// GenerateOptions control how Generate builds a document
+type GenerateOptions struct {
+	// MaxKeywords bounds the keywords of each index entry; zero uses
+	// DefaultMaxKeywords
+	MaxKeywords int
+
+	// Generated stamps the document; zero uses the current time
+	Generated time.Time
+}
+
+// Generate builds an AIO document from the HTML (.html, .htm) and
+// Markdown (.md, .markdown) pages under dir, for site owners publishing
+// the format. Each page is split into chunks at its top-level headings;
+// every chunk gets an ID derived from its page path and heading, so IDs
+// stay stable as pages are edited, a SHA-256 hash, and an index entry with
+// a title, summary and keywords drawn from its text. Hidden files and
+// directories and existing .aio files are skipped.
+func Generate(dir string, opts GenerateOptions) (*AIOFile, error) {
+	generated := opts.Generated
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	maxKeywords := opts.MaxKeywords
+	if maxKeywords <= 0 {
+		maxKeywords = DefaultMaxKeywords
+	}
+	a := &AIOFile{
+		Schema:    contentSchemaURL,
+		Version:   maxVersion,
+		Generated: generated.UTC().Format(time.RFC3339),
+	}
+
+	ids := map[string]bool{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if d.IsDir() || !pageFormats[ext] {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		var title string
+		var sections []pageSection
+		if ext == ".html" || ext == ".htm" {
+			title, sections, err = htmlSections(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+		} else {
+			title, sections = markdownSections(string(data))
+		}
+		addPage(a, ids, pagePath(filepath.ToSlash(rel)), title, sections, maxKeywords)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Content) == 0 {
+		return nil, errors.New("no HTML or Markdown pages with content under " + dir)
+	}
+	return a, nil
+}
+
+// Encode writes a document as indented JSON, as published
+func Encode(a *AIOFile) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pageFormats are the extensions Generate reads
+var pageFormats = map[string]bool{".html": true, ".htm": true, ".md": true, ".markdown": true}
+
+// pageSection is one heading-delimited part of a page
+type pageSection struct {
+	heading string
+	content string
+}
+
+// addPage appends a page's sections to a as chunks with index entries.
+// The first section takes the page's ID, so a page that is not split
+// keeps one ID however its headings change.
+func addPage(a *AIOFile, ids map[string]bool, pagePath, title string, sections []pageSection, maxKeywords int) {
+	base := pageID(pagePath)
+	for i, s := range sections {
+		id, anchor := base, ""
+		if i > 0 {
+			anchor = slugify(s.heading)
+			if anchor == "" {
+				anchor = fmt.Sprint(i + 1)
+			}
+			id = base + "-" + anchor
+		}
+		id = uniqueID(ids, id)
+
+		heading := s.heading
+		if heading == "" {
+			heading = title
+		}
+		p := pagePath
+		if anchor != "" {
+			p += "#" + anchor
+		}
+		a.Content = append(a.Content, Chunk{
+			ID:      id,
+			Format:  "markdown",
+			Content: s.content,
+			Hash:    "sha256:" + sha256Hex(s.content),
+		})
+		a.Index = append(a.Index, IndexEntry{
+			ID:       id,
+			Path:     p,
+			Title:    heading,
+			Keywords: extractKeywords(heading, s.content, maxKeywords),
+			Summary:  summarize(s.content),
+		})
+	}
+}
+
+// pagePath is the URL path a page is served at: its file path, with
+// index pages and Markdown READMEs standing for their directory and
+// Markdown files without an extension
+func pagePath(rel string) string {
+	ext := path.Ext(rel)
+	name := strings.TrimSuffix(path.Base(rel), ext)
+	isHTML := ext == ".html" || ext == ".htm"
+	if strings.EqualFold(name, "index") || !isHTML && strings.EqualFold(name, "readme") {
+		if dir := path.Dir(rel); dir != "." {
+			return "/" + dir + "/"
+		}
+		return "/"
+	}
+	if isHTML {
+		return "/" + rel
+	}
+	return "/" + strings.TrimSuffix(rel, ext)
+}
+
+// pageID derives a chunk ID from a page path: "/" is "home", and
+// "/docs/setup.html" is "docs-setup"
+func pageID(pagePath string) string {
+	p := strings.TrimSuffix(strings.TrimSuffix(pagePath, ".html"), ".htm")
+	if id := slugify(p); id != "" {
+		return id
+	}
+	return "home"
+}
+
+// uniqueID returns id, or id with the first free numeric suffix, and
+// records it as used
+func uniqueID(ids map[string]bool, id string) string {
+	candidate := id
+	for n := 2; ids[candidate]; n++ {
+		candidate = fmt.Sprintf("%s-%d", id, n)
+	}
+	ids[candidate] = true
+	return candidate
+}
+
+// slugify lower-cases s and joins its runs of letters and digits with
+// hyphens
+func slugify(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// markdownSections splits a Markdown page at its level 1 and 2 headings,
+// each section keeping its heading line. A leading front matter block is
+// dropped, its title: line giving the page title; otherwise the first
+// heading does.
+func markdownSections(text string) (string, []pageSection) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var title string
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if front, body, ok := strings.Cut(rest, "\n---\n"); ok {
+			for _, line := range strings.Split(front, "\n") {
+				if v, ok := strings.CutPrefix(line, "title:"); ok {
+					title = strings.Trim(strings.TrimSpace(v), `"'`)
+				}
+			}
+			text = body
+		}
+	}
+
+	var sections []pageSection
+	var cur pageSection
+	var lines []string
+	fence := ""
+	flush := func() {
+		if cur.content = strings.TrimSpace(strings.Join(lines, "\n")); cur.content != "" {
+			sections = append(sections, cur)
+		}
+		lines = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		default:
+			if heading, ok := markdownHeading(trimmed); ok {
+				flush()
+				cur = pageSection{heading: heading}
+				if title == "" {
+					title = heading
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return title, sections
+}
+
+// markdownHeading returns the text of a level 1 or 2 ATX heading line
+func markdownHeading(line string) (string, bool) {
+	for _, prefix := range []string{"# ", "## "} {
+		if text, ok := strings.CutPrefix(line, prefix); ok {
+			return strings.TrimSpace(strings.TrimRight(text, "# ")), true
+		}
+	}
+	return "", false
+}
+
+// htmlSections reads an HTML page's sections the way the scraper does,
+// writing each heading as a Markdown heading line. The title is the
+// page's <title>, or else its first section heading.
+func htmlSections(page []byte) (string, []pageSection, error) {
+	chunks, err := scrapeChunks(page)
+	if err != nil {
+		return "", nil, err
+	}
+	var title string
+	if doc, err := html.Parse(bytes.NewReader(page)); err == nil {
+		if t := findElement(doc, "title"); t != nil {
+			title = collapseSpace(nodeText(t))
+		}
+	}
+	sections := make([]pageSection, len(chunks))
+	for i, c := range chunks {
+		content := c.Content
+		if c.Section != "" {
+			content = "## " + c.Section + "\n\n" + content
+		}
+		sections[i] = pageSection{heading: c.Section, content: content}
+		if title == "" {
+			title = c.Section
+		}
+	}
+	return title, sections, nil
+}
+
+// extractKeywords picks the n most frequent words of a chunk of at least
+// three letters that are not stopwords, counting words of the title
+// three times. Ties are broken alphabetically so output is stable.
+func extractKeywords(title, content string, n int) []string {
+	stop := make(map[string]bool, len(DefaultStopwords))
+	for _, w := range DefaultStopwords {
+		stop[w] = true
+	}
+	counts := map[string]int{}
+	count := func(text string, weight int) {
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(w)) < 3 || stop[w] || strings.IndexFunc(w, unicode.IsLetter) < 0 {
+				continue
+			}
+			counts[w] += weight
+		}
+	}
+	count(title, 3)
+	count(content, 1)
+
+	words := make([]string, 0, len(counts))
+	for w := range counts {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] != counts[words[j]] {
+			return counts[words[i]] > counts[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > n {
+		words = words[:n]
+	}
+	return words
+}
+
+// summarize returns the first two sentences of a chunk, skipping its
+// heading lines
+func summarize(content string) string {
+	var body []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			body = append(body, line)
+		}
+	}
+	sentences := splitSentences(strings.Join(body, "\n"))
+	if len(sentences) > 2 {
+		sentences = sentences[:2]
+	}
+	return strings.Join(sentences, " ")
+}
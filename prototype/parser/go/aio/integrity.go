@@ -0,0 +1,153 @@
+package aio
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+)
+
+// HashMismatch reports a chunk whose content does not match its published
+// hash
+type HashMismatch struct {
+	ChunkID  string `json:"chunk_id"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+
+	// MatchedAfter names the newline normalization under which the content
+	// does match the hash, pointing to an encoding difference rather than
+	// tampering. Empty when no normalization matched.
+	MatchedAfter string `json:"matched_after,omitempty"`
+}
+
+// newlineNormalizations are tried in order when a hash fails on the raw
+// content, to tell publishing accidents apart from real changes
+var newlineNormalizations = []struct {
+	name  string
+	apply func(string) string
+}{
+	{"crlf-to-lf", func(s string) string { return strings.ReplaceAll(s, "\r\n", "\n") }},
+	{"lf-to-crlf", func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	}},
+	{"trim-trailing-newline", func(s string) string { return strings.TrimRight(s, "\r\n") }},
+	{"add-trailing-newline", func(s string) string { return s + "\n" }},
+}
+
+// ErrUnsupportedHash is returned when a chunk names a hash algorithm the
+// parser cannot compute
+var ErrUnsupportedHash = errors.New("aio: unsupported hash algorithm")
+
+// ErrHashMismatch is returned under Options.StrictHashes for a document
+// with a chunk whose content does not match its hash
+var ErrHashMismatch = errors.New("aio: chunk content does not match its hash")
+
+// checkDocumentHashes verifies every chunk of a document for
+// Options.StrictHashes, failing on the first mismatch
+func checkDocumentHashes(content []Chunk) error {
+	mismatches, err := verifyHashes(append([]Chunk(nil), content...))
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		m := mismatches[0]
+		err := fmt.Errorf("%w: chunk %q has %s, want %s", ErrHashMismatch, m.ChunkID, m.Actual, m.Expected)
+		if len(mismatches) > 1 {
+			err = fmt.Errorf("%w (and %d more chunks)", err, len(mismatches)-1)
+		}
+		return err
+	}
+	return nil
+}
+
+// hashAlgorithms maps accepted algorithm names to their constructors
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256":      sha256.New,
+	"sha-256":     sha256.New,
+	"sha1":        sha1.New,
+	"sha-1":       sha1.New,
+	"blake2b":     newBlake2b512,
+	"blake2b-512": newBlake2b512,
+	"blake2b-256": newBlake2b256,
+	"blake2s":     newBlake2s256,
+	"blake2s-256": newBlake2s256,
+}
+
+// verifyHashes checks each chunk's content against its hash, setting
+// Verified on matches and returning the mismatches. Chunks without a hash,
+// or whose digest is malformed, are left unverified; an unknown algorithm
+// fails with ErrUnsupportedHash.
+func verifyHashes(chunks []Chunk) ([]HashMismatch, error) {
+	var mismatches []HashMismatch
+	for i := range chunks {
+		algo, expected, err := parseHash(chunks[i].Hash, chunks[i].HashAlgo)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %q: %w", chunks[i].ID, err)
+		}
+		if expected == "" {
+			continue
+		}
+		actual := digestHex(algo, chunks[i].Content)
+		if actual == expected {
+			chunks[i].Verified = true
+			continue
+		}
+
+		m := HashMismatch{ChunkID: chunks[i].ID, Expected: expected, Actual: actual}
+		for _, n := range newlineNormalizations {
+			if digestHex(algo, n.apply(chunks[i].Content)) == expected {
+				m.MatchedAfter = n.name
+				break
+			}
+		}
+		mismatches = append(mismatches, m)
+	}
+	return mismatches, nil
+}
+
+// parseHash splits a chunk hash into its algorithm and hex digest. The
+// algorithm comes from an "algo:" prefix, else from the chunk's hash_algo
+// field, else defaults to SHA-256. The digest is empty when there is no
+// hash or it is not well-formed hex of the algorithm's size.
+func parseHash(h, hashAlgo string) (func() hash.Hash, string, error) {
+	digest := strings.ToLower(strings.TrimSpace(h))
+	name := strings.ToLower(strings.TrimSpace(hashAlgo))
+	if prefix, rest, ok := strings.Cut(digest, ":"); ok {
+		name, digest = prefix, rest
+	}
+	if name == "" {
+		name = "sha256"
+	}
+	algo, ok := hashAlgorithms[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%w %q", ErrUnsupportedHash, name)
+	}
+	if len(digest) != algo().Size()*2 {
+		return algo, "", nil
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return algo, "", nil
+	}
+	return algo, digest, nil
+}
+
+func digestHex(algo func() hash.Hash, s string) string {
+	h := algo()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sha256Hex(s string) string {
+	return digestHex(sha256.New, s)
+}
+
+// The blake2 constructors only fail for bad keys, and these are unkeyed
+func newBlake2b512() hash.Hash { h, _ := blake2b.New512(nil); return h }
+func newBlake2b256() hash.Hash { h, _ := blake2b.New256(nil); return h }
+func newBlake2s256() hash.Hash { h, _ := blake2s.New256(nil); return h }
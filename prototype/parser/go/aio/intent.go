@@ -0,0 +1,40 @@
+package aio
+
+import "strings"
+
+// Intent is the kind of goal behind a query, used to favour chunks whose
+// category suits it (Options.Intent)
+type Intent string
+
+const (
+	IntentInformational Intent = "informational"
+	IntentTransactional Intent = "transactional"
+	IntentNavigational  Intent = "navigational"
+)
+
+// intentBoost multiplies the score of a chunk whose category suits the
+// intent
+const intentBoost = 1.5
+
+// intentCategories lists the chunk categories each intent favours
+var intentCategories = map[Intent][]string{
+	IntentInformational: {"article", "guide", "faq", "documentation", "docs", "about", "blog", "overview"},
+	IntentTransactional: {"pricing", "product", "cta", "checkout", "signup", "offer", "plans", "purchase"},
+	IntentNavigational:  {"contact", "location", "navigation", "home", "support", "directory"},
+}
+
+// boost returns the score multiplier for a chunk of the given category:
+// intentBoost when the intent favours it, 1 otherwise, including when the
+// chunk has no category or the intent is unknown.
+func (i Intent) boost(category string) float64 {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if category == "" {
+		return 1
+	}
+	for _, c := range intentCategories[Intent(strings.ToLower(string(i)))] {
+		if c == category {
+			return intentBoost
+		}
+	}
+	return 1
+}
@@ -0,0 +1,187 @@
+package aio
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minDetectConfidence is the confidence below which a detected document
+// language is reported but not used to pick the token estimator
+const minDetectConfidence = 0.5
+
+// languageSampleSize bounds how much of a document is read to detect its
+// language; a few kilobytes are plenty and keep detection cheap
+const languageSampleSize = 16 << 10
+
+// scriptLanguages map writing systems used by essentially one language
+// (or one we can treat alike) to its code
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// latinProfiles are the most frequent function words of languages written
+// in the Latin script, which tell them apart on a handful of sentences
+var latinProfiles = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "it", "with", "you", "are", "this", "on"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "zu", "sie", "auf", "für", "ich"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "pour", "dans", "que", "qui", "pas", "du", "sur"},
+	"es": {"el", "la", "los", "y", "que", "es", "en", "una", "por", "para", "con", "las", "del", "se"},
+	"it": {"il", "di", "che", "la", "è", "e", "una", "per", "non", "sono", "gli", "del", "con", "della"},
+	"pt": {"o", "os", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "são", "dos"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "ook"},
+}
+
+// latinWords indexes latinProfiles by word
+var latinWords = func() map[string][]string {
+	words := map[string][]string{}
+	for lang, profile := range latinProfiles {
+		for _, w := range profile {
+			words[w] = append(words[w], lang)
+		}
+	}
+	return words
+}()
+
+// detectLanguage guesses the language of text, returning an ISO 639-1
+// code and a confidence between 0 and 1, or "" and 0 when there is too
+// little evidence. Non-Latin scripts are recognized by their characters;
+// Latin-script languages by their common function words.
+func detectLanguage(text string) (string, float64) {
+	scripts := map[string]int{}
+	letters, latin := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scripts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+
+	// Japanese mixes kana with Han characters, so any notable kana share
+	// means Japanese rather than Chinese
+	if scripts["ja"] > 0 && scripts["ja"]*10 >= scripts["zh"] {
+		scripts["ja"] += scripts["zh"]
+		delete(scripts, "zh")
+	}
+	best, bestCount := "", 0
+	for lang, n := range scripts {
+		if n > bestCount {
+			best, bestCount = lang, n
+		}
+	}
+	if bestCount > latin {
+		return best, float64(bestCount) / float64(letters)
+	}
+	return detectLatin(text)
+}
+
+// detectLatin scores text against latinProfiles. Confidence is the best
+// language's share of all profile hits, scaled down when there are few.
+func detectLatin(text string) (string, float64) {
+	hits := map[string]int{}
+	total := 0
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range latinWords[w] {
+			hits[lang]++
+			total++
+		}
+	}
+	best, bestHits := "", 0
+	for lang, n := range hits {
+		if n > bestHits || (n == bestHits && lang < best) {
+			best, bestHits = lang, n
+		}
+	}
+	if bestHits == 0 {
+		return "", 0
+	}
+	confidence := float64(bestHits) / float64(total)
+	if bestHits < 5 {
+		confidence *= float64(bestHits) / 5
+	}
+	return best, confidence
+}
+
+// languageSample joins chunk contents up to languageSampleSize bytes
+func languageSample(chunks []Chunk) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		if b.Len() >= languageSampleSize {
+			break
+		}
+		b.WriteString(c.Content)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// chunkLanguage is the language a chunk is matched in and, for a chunk
+// that declares none, the guess detected from its content
+type chunkLanguage struct {
+	lang       string
+	detected   string
+	confidence float64
+}
+
+// chunkLanguages works out the language of each chunk of content: the
+// one it declares, else under detect a confident guess from its content,
+// else docLang
+func chunkLanguages(content []Chunk, docLang string, detect bool) []chunkLanguage {
+	langs := make([]chunkLanguage, len(content))
+	for i, c := range content {
+		l := &langs[i]
+		l.lang = c.Language
+		if l.lang == "" && detect {
+			l.detected, l.confidence = detectLanguage(c.Content)
+			if l.confidence >= minDetectConfidence {
+				l.lang = l.detected
+			}
+		}
+		if l.lang == "" {
+			l.lang = docLang
+		}
+	}
+	return langs
+}
+
+// inLanguages reports whether lang is one of wanted, comparing primary
+// subtags so "en" takes in "en-GB". A chunk of unknown language cannot be
+// ruled out and is kept.
+func inLanguages(lang string, wanted []string) bool {
+	if lang == "" {
+		return true
+	}
+	base := baseLanguage(lang)
+	for _, w := range wanted {
+		if baseLanguage(w) == base {
+			return true
+		}
+	}
+	return false
+}
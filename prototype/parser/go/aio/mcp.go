@@ -0,0 +1,387 @@
+package aio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MCPProtocolVersion is the Model Context Protocol revision MCPServer
+// speaks
+const MCPProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes MCPServer answers with
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// MCPServer exposes a Parser to Model Context Protocol clients as tools:
+//
+//	fetch_aio(url, query, max_tokens, format)  the chunks of url matching
+//	                                            query, rendered as md (the
+//	                                            default), text or jsonl
+//	discover_aio(url)                           where url publishes its AIO
+//	                                            document, as JSON
+//
+// It serves over stdio (ServeStdio), as MCP clients launch local servers,
+// or over HTTP with Server-Sent Events (Handler).
+type MCPServer struct {
+	parser *Parser
+
+	mu       sync.Mutex
+	sessions map[string]*mcpSession // SSE sessions by ID
+}
+
+// mcpSession is an open event stream of the HTTP transport
+type mcpSession struct {
+	ctx context.Context // ends when the stream closes
+	out chan []byte
+}
+
+// NewMCPServer returns an MCPServer answering tool calls with p. The
+// URLs come from the model, so Options.TrustParseHost is ignored.
+func NewMCPServer(p *Parser) *MCPServer {
+	return &MCPServer{parser: untrusted(p), sessions: map[string]*mcpSession{}}
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool describes a tool in a tools/list result
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// mcpContent is one content block of a tools/call result
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "fetch_aio",
+		Description: "Fetch a website's AIO content (ai-content.aio, or the page itself when it publishes none) and return the chunks matching a query, each with its source URL.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url":        map[string]any{"type": "string", "description": "site or document URL"},
+				"query":      map[string]any{"type": "string", "description": "keywords selecting the chunks; empty returns every chunk"},
+				"max_tokens": map[string]any{"type": "integer", "description": "token budget for the returned content; 0 for none"},
+				"format":     map[string]any{"type": "string", "enum": []string{"md", "text", "jsonl"}, "description": "output format, md by default"},
+			},
+			"required": []string{"url"},
+		},
+	},
+	{
+		Name:        "discover_aio",
+		Description: "Report where a website publishes its AIO document and how it was found.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url": map[string]any{"type": "string", "description": "site URL"},
+			},
+			"required": []string{"url"},
+		},
+	},
+}
+
+// ServeStdio reads newline-delimited JSON-RPC messages from r and writes
+// the responses to w until r ends or ctx is cancelled. Requests are
+// answered concurrently, so a slow fetch does not hold up the others.
+func (s *MCPServer) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wmu sync.Mutex
+		wg  sync.WaitGroup
+	)
+	defer wg.Wait()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			wg.Add(1)
+			go func(msg []byte) {
+				defer wg.Done()
+				if resp := s.handle(ctx, msg); resp != nil {
+					wmu.Lock()
+					w.Write(append(resp, '\n'))
+					wmu.Unlock()
+				}
+			}(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Handler serves the HTTP transport of MCP: a client opens an event
+// stream with GET /sse, is sent the URL to POST its messages to in an
+// "endpoint" event, and receives each response as a "message" event.
+func (s *MCPServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		id, err := sessionID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sess := &mcpSession{ctx: r.Context(), out: make(chan []byte, 16)}
+		s.mu.Lock()
+		s.sessions[id] = sess
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.sessions, id)
+			s.mu.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\n\n", id)
+		flusher.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case msg := <-sess.out:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+				flusher.Flush()
+			}
+		}
+	})
+	mux.HandleFunc("/message", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		s.mu.Lock()
+		sess, ok := s.sessions[r.URL.Query().Get("sessionId")]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		msg, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+		// The answer goes out on the event stream after this request has
+		// ended, so it is bound to the stream rather than the request
+		go func() {
+			if resp := s.handle(sess.ctx, msg); resp != nil {
+				select {
+				case sess.out <- resp:
+				case <-sess.ctx.Done():
+				}
+			}
+		}()
+	})
+	return mux
+}
+
+func sessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handle answers one JSON-RPC message or batch, returning nil when
+// nothing is owed in reply, as for notifications
+func (s *MCPServer) handle(ctx context.Context, msg []byte) []byte {
+	if jsonType(msg) == "an array" {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil || len(batch) == 0 {
+			return encodeResponse(rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{rpcInvalidRequest, "invalid batch"}})
+		}
+		var replies []json.RawMessage
+		for _, m := range batch {
+			if resp := s.handle(ctx, m); resp != nil {
+				replies = append(replies, resp)
+			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		out, _ := json.Marshal(replies)
+		return out
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return encodeResponse(rpcResponse{ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}})
+	}
+	if req.Method == "" {
+		// A response to a request of ours; the server sends none
+		return nil
+	}
+	result, rerr := s.call(ctx, req)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	return encodeResponse(rpcResponse{ID: req.ID, Result: result, Error: rerr})
+}
+
+func encodeResponse(resp rpcResponse) []byte {
+	resp.JSONRPC = "2.0"
+	out, err := json.Marshal(resp)
+	if err != nil {
+		out, _ = json.Marshal(rpcResponse{JSONRPC: "2.0", ID: resp.ID, Error: &rpcError{rpcInvalidRequest, err.Error()}})
+	}
+	return out
+}
+
+// call runs one method
+func (s *MCPServer) call(ctx context.Context, req rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": MCPProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "aio-parser-go", "version": "0.1"},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		return s.callTool(ctx, params.Name, params.Arguments)
+	}
+	if strings.HasPrefix(req.Method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, "method not found: " + req.Method}
+}
+
+// callTool runs a tool. Failures of the tool itself, such as a site that
+// cannot be fetched, are results marked isError for the model to read;
+// only malformed calls are protocol errors.
+func (s *MCPServer) callTool(ctx context.Context, name string, arguments json.RawMessage) (any, *rpcError) {
+	var args struct {
+		URL       string `json:"url"`
+		Query     string `json:"query"`
+		MaxTokens int    `json:"max_tokens"`
+		Format    string `json:"format"`
+	}
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	if args.URL == "" && (name == "fetch_aio" || name == "discover_aio") {
+		return nil, &rpcError{rpcInvalidParams, "url is required"}
+	}
+
+	var text string
+	var err error
+	switch name {
+	case "fetch_aio":
+		text, err = s.fetch(ctx, args.URL, args.Query, args.MaxTokens, args.Format)
+	case "discover_aio":
+		var found *Discovery
+		if found, err = s.parser.Discover(ctx, args.URL); err == nil {
+			var out []byte
+			out, err = json.MarshalIndent(found, "", "  ")
+			text = string(out)
+		}
+	default:
+		return nil, &rpcError{rpcInvalidParams, "unknown tool: " + name}
+	}
+	if err != nil {
+		return mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+}
+
+// fetch runs fetch_aio: a parse with the server's parser, its budget
+// replaced by maxTokens when that is set
+func (s *MCPServer) fetch(ctx context.Context, url, query string, maxTokens int, format string) (string, error) {
+	f := FormatMarkdown
+	if format != "" {
+		var err error
+		if f, err = ParseFormat(format); err != nil {
+			return "", err
+		}
+	}
+	// Only the chunks are rendered, so no narrative is built
+	p := *s.parser
+	p.Options.ItemsOnly = true
+	if maxTokens > 0 {
+		p.Options.MaxTokens = maxTokens
+	}
+	env, err := p.ParseContext(ctx, url, query)
+	if err != nil {
+		return "", err
+	}
+	if len(env.Items) == 0 {
+		msg := fmt.Sprintf("No content at %s matched %q.", url, query)
+		if len(env.Suggestions) > 0 {
+			msg += " Did you mean: " + strings.Join(env.Suggestions, ", ") + "?"
+		}
+		return msg, nil
+	}
+	out, err := env.Render(f)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
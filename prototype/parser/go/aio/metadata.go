@@ -0,0 +1,49 @@
+package aio
+
+// Metadata describes a document as a whole, for citing it and for
+// deciding whether it may be used
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Language is the ISO 639-1 language of the content; the top-level
+	// language field takes precedence when both are set
+	Language string `json:"language,omitempty"`
+
+	// CanonicalURL is the address the content should be cited by
+	CanonicalURL string `json:"canonical_url,omitempty"`
+
+	// License names the terms the content is published under, as an SPDX
+	// identifier such as "CC-BY-4.0" or a URL
+	License string `json:"license,omitempty"`
+
+	// LastUpdated is when the content last changed (RFC 3339). Chunks
+	// with no date of their own are dated by it under Options.MaxAge.
+	LastUpdated string `json:"last_updated,omitempty"`
+
+	Author string `json:"author,omitempty"`
+}
+
+// documentLanguage is the language a document declares, at the top level
+// or in its metadata
+func documentLanguage(a *AIOFile) string {
+	if a.Language == "" && a.Metadata != nil {
+		return a.Metadata.Language
+	}
+	return a.Language
+}
+
+// envelopeMetadata is the metadata an envelope carries: the document's,
+// with the top-level language folded in. It is nil when the document
+// declares none.
+func envelopeMetadata(a *AIOFile) *Metadata {
+	var m Metadata
+	if a.Metadata != nil {
+		m = *a.Metadata
+	}
+	m.Language = documentLanguage(a)
+	if m == (Metadata{}) {
+		return nil
+	}
+	return &m
+}
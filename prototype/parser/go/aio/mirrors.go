@@ -0,0 +1,93 @@
+package aio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// loadMirrors tries url and then each of Options.Mirrors until one loads,
+// or in order of measured latency under Options.MirrorsByLatency. The
+// envelope's SourceURL names the mirror that served it.
+func (p *Parser) loadMirrors(ctx context.Context, url string) (*document, error) {
+	bases := append([]string{url}, p.Options.Mirrors...)
+	if p.Options.MirrorsByLatency {
+		bases = p.byLatency(ctx, bases)
+	}
+
+	var errs []error
+	for _, base := range bases {
+		doc, err := p.load(ctx, base)
+		if err == nil {
+			return doc, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		clean, _ := stripCredentials(base)
+		p.logf("aio: mirror %s failed, trying the next: %v", clean, err)
+		errs = append(errs, fmt.Errorf("mirror %s: %w", clean, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// byLatency orders bases by the round trip of a HEAD request to each,
+// made concurrently. Mirrors that do not answer keep their relative order
+// after the ones that do.
+func (p *Parser) byLatency(ctx context.Context, bases []string) []string {
+	latency := make([]time.Duration, len(bases))
+	var wg sync.WaitGroup
+	for i, base := range bases {
+		wg.Add(1)
+		go func(i int, base string) {
+			defer wg.Done()
+			latency[i] = p.probe(ctx, base)
+		}(i, base)
+	}
+	wg.Wait()
+
+	order := make([]int, len(bases))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		la, lb := latency[order[a]], latency[order[b]]
+		if la < 0 || lb < 0 {
+			return lb < 0 && la >= 0
+		}
+		return la < lb
+	})
+	sorted := make([]string, len(bases))
+	for i, j := range order {
+		sorted[i] = bases[j]
+	}
+	return sorted
+}
+
+// probe times a HEAD request to base, returning -1 when it fails
+func (p *Parser) probe(ctx context.Context, base string) time.Duration {
+	url, urlAuth := stripCredentials(base)
+	sess, err := newSession(ctx, p.Options, p.Client, url, urlAuth, newParseRun(Options{}))
+	if err != nil {
+		return -1
+	}
+	defer sess.close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return -1
+	}
+	for k, v := range sess.header {
+		req.Header[k] = v
+	}
+	started := time.Now()
+	resp, err := sess.do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	return time.Since(started)
+}
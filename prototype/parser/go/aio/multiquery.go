@@ -0,0 +1,109 @@
+package aio
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WeightedQuery is one query of ParseMultiQuery with its share of the
+// token budget. A weight of zero or less counts as 1.
+type WeightedQuery struct {
+	Query  string
+	Weight float64
+}
+
+// MultiQueryResult holds one envelope per query of ParseMultiQuery
+type MultiQueryResult struct {
+	Envelopes []*ContentEnvelope `json:"envelopes"`
+
+	// Narrative joins the envelopes' narratives in query order when
+	// Options.CombineNarratives is set, and Tokens is its token count
+	Narrative string `json:"narrative,omitempty"`
+	Tokens    int    `json:"tokens,omitempty"`
+}
+
+// ParseMultiQuery fetches url once and answers several queries from it.
+// Options.MaxTokens is shared between the queries in proportion to their
+// weights, so the envelopes together stay within the budget; without a
+// budget each query is selected as Parse would. Every query gets at least
+// one token, so a budget smaller than the number of queries is exceeded
+// by the difference at most.
+func (p *Parser) ParseMultiQuery(ctx context.Context, url string, queries []WeightedQuery) (*MultiQueryResult, error) {
+	started := time.Now()
+	doc, err := p.open(ctx, url)
+	if err != nil {
+		p.parsed(url, started, nil, err)
+		return nil, err
+	}
+	defer doc.close()
+
+	shares := budgetShares(p.Options.MaxTokens, queries)
+	result := &MultiQueryResult{}
+	var narratives []string
+	for i, q := range queries {
+		opts := p.Options
+		if opts.MaxTokens > 0 {
+			opts.MaxTokens = shares[i]
+		}
+		env, err := doc.envelope(q.Query, opts)
+		p.parsed(url, started, env, err)
+		if err != nil {
+			return nil, err
+		}
+		result.Envelopes = append(result.Envelopes, env)
+		if env.Narrative != "" {
+			narratives = append(narratives, strings.TrimSuffix(env.Narrative, chunkSeparator))
+		}
+	}
+	if p.Options.CombineNarratives {
+		result.Narrative = strings.Join(narratives, chunkSeparator)
+		for _, env := range result.Envelopes {
+			result.Tokens += env.Tokens
+		}
+	}
+	return result, nil
+}
+
+// budgetShares splits budget between queries in proportion to their
+// weights, each getting at least one token, since a MaxTokens of zero
+// means no limit. Shares are rounded down and the tokens left over go to
+// the largest remainders, so they add up to budget.
+func budgetShares(budget int, queries []WeightedQuery) []int {
+	shares := make([]int, len(queries))
+	for i := range shares {
+		shares[i] = 1
+	}
+	spare := budget - len(queries)
+	if spare <= 0 {
+		return shares
+	}
+	total := 0.0
+	for _, q := range queries {
+		total += queryWeight(q)
+	}
+	left := spare
+	remainders := make([]float64, len(queries))
+	order := make([]int, len(queries))
+	for i, q := range queries {
+		exact := float64(spare) * queryWeight(q) / total
+		shares[i] += int(exact)
+		left -= int(exact)
+		remainders[i] = exact - math.Floor(exact)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:left] {
+		shares[i]++
+	}
+	return shares
+}
+
+func queryWeight(q WeightedQuery) float64 {
+	if q.Weight <= 0 {
+		return 1
+	}
+	return q.Weight
+}
@@ -0,0 +1,56 @@
+package aio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBudgetShares(t *testing.T) {
+	tests := []struct {
+		budget  int
+		weights []float64
+		want    []int
+	}{
+		{30, []float64{1, 100}, []int{1, 29}},
+		{30, []float64{1, 1, 1}, []int{10, 10, 10}},
+		{10, []float64{1, 1, 1}, []int{4, 3, 3}},
+		{100, []float64{3, 1}, []int{75, 25}},
+		{7, []float64{0, -2, 5}, []int{2, 1, 4}},
+		{2, []float64{1, 1, 1}, []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		var queries []WeightedQuery
+		for _, w := range tt.weights {
+			queries = append(queries, WeightedQuery{Weight: w})
+		}
+		got := budgetShares(tt.budget, queries)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("budgetShares(%d, %v) = %v, want %v", tt.budget, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestParseMultiQueryBudget(t *testing.T) {
+	var chunks []string
+	for i := 0; i < 20; i++ {
+		chunks = append(chunks, fmt.Sprintf(`{"id": "c%d", "content": "Pricing and plans, part %d of the guide."}`, i, i))
+	}
+	srv := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "content": [` + strings.Join(chunks, ", ") + `]}`
+	})
+	p := &Parser{Options: Options{AllowPrivateNetworks: true, IgnoreRobots: true, MaxTokens: 30, CombineNarratives: true}}
+	result, err := p.ParseMultiQuery(context.Background(), srv.URL+"/", []WeightedQuery{{Query: "pricing", Weight: 1}, {Query: "plans", Weight: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, env := range result.Envelopes {
+		total += env.Tokens
+	}
+	if total > 30 || result.Tokens > 30 {
+		t.Errorf("envelopes cost %d tokens together (Tokens %d), over MaxTokens 30", total, result.Tokens)
+	}
+}
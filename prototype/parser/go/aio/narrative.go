@@ -0,0 +1,65 @@
+package aio
+
+import "strings"
+
+// Span locates one block of the narrative by byte offsets and names the
+// chunks it was assembled from
+type Span struct {
+	Start    int      `json:"start"`
+	End      int      `json:"end"`
+	ChunkIDs []string `json:"chunk_ids"`
+
+	// Merged is set when the block joins several chunks that were adjacent
+	// in the document (Options.MergeAdjacent)
+	Merged bool `json:"merged,omitempty"`
+
+	// Citations link the block back to its chunks' source lines, for
+	// chunks that carry line metadata
+	Citations []string `json:"citations,omitempty"`
+}
+
+// buildNarrative concatenates chunk contents, each block followed by the
+// chunk separator. With merge, a chunk that directly follows its
+// predecessor in document order continues the same block instead.
+func buildNarrative(chunks []Chunk, merge bool) (string, []Span) {
+	var b strings.Builder
+	var spans []Span
+	for i, chunk := range chunks {
+		if merge && i > 0 && chunk.pos == chunks[i-1].pos+1 {
+			// Reopen the previous block: drop its separator and keep a
+			// line break so the two chunks don't run together
+			last := &spans[len(spans)-1]
+			text := strings.TrimSuffix(b.String(), chunkSeparator)
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			b.Reset()
+			b.WriteString(text)
+			b.WriteString(chunk.Content)
+			last.End = b.Len()
+			last.ChunkIDs = append(last.ChunkIDs, chunk.ID)
+			last.Merged = true
+			last.Citations = appendCitation(last.Citations, chunk)
+			b.WriteString(chunkSeparator)
+			continue
+		}
+
+		start := b.Len()
+		b.WriteString(chunk.Content)
+		spans = append(spans, Span{
+			Start:     start,
+			End:       b.Len(),
+			ChunkIDs:  []string{chunk.ID},
+			Citations: appendCitation(nil, chunk),
+		})
+		b.WriteString(chunkSeparator)
+	}
+	return b.String(), spans
+}
+
+func appendCitation(citations []string, c Chunk) []string {
+	if c.Citation == "" {
+		return citations
+	}
+	return append(citations, c.Citation)
+}
@@ -0,0 +1,369 @@
+package aio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics counts what a Parser does, for dashboards and alerts
+// (Parser.Metrics). Its methods are called synchronously from concurrent
+// parses and should not block.
+type Metrics interface {
+	// Fetch is called once per document or page requested, whether it
+	// came from the network or the cache
+	Fetch(FetchEvent)
+
+	// Fallback is called for each source strategy that failed before
+	// another was tried
+	Fallback(FallbackEvent)
+
+	// Parse is called once per parse, succeeded or failed
+	Parse(ParseEvent)
+}
+
+// CacheStatus says how a fetch used the Cache
+type CacheStatus string
+
+const (
+	CacheMiss        CacheStatus = "miss"        // downloaded; also when there is no Cache
+	CacheHit         CacheStatus = "hit"         // served from the Cache without a request
+	CacheRevalidated CacheStatus = "revalidated" // the server answered 304 to a cached entry
+)
+
+// FetchEvent describes one fetch
+type FetchEvent struct {
+	URL string
+
+	// Status is the HTTP status of the response, 0 when there was none
+	Status int
+
+	// Bytes is the size of the body after decoding
+	Bytes    int64
+	Cache    CacheStatus
+	Duration time.Duration
+	Err      error
+}
+
+// FallbackEvent describes a source strategy that failed to find a
+// document at URL
+type FallbackEvent struct {
+	URL      string
+	Strategy SourceStrategy
+	Err      error
+}
+
+// ParseEvent describes one parse
+type ParseEvent struct {
+	URL string
+
+	// Source is the strategy that found the document, empty when none did
+	Source SourceStrategy
+
+	// Chunks is the size of the document and Selected how many of its
+	// chunks the query selected, together the selectivity of retrieval
+	Chunks   int
+	Selected int
+
+	Duration time.Duration
+	Err      error
+}
+
+// Tracer starts a span around each stage of a parse (Parser.Tracer), for
+// an adapter to OpenTelemetry or another tracing system. The stages are
+// named by the Stage constants, and every span of a parse is started from
+// the context it was called with.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, TraceSpan)
+}
+
+// TraceSpan is a stage of a parse started by a Tracer. End is called
+// once, with the stage's error, if any, and attributes describing its
+// result.
+type TraceSpan interface {
+	End(err error, attrs ...slog.Attr)
+}
+
+// Stage names of the spans a Tracer starts
+const (
+	StageDiscovery = "aio.discovery" // locating the document through the fallback chain
+	StageFetch     = "aio.fetch"     // one request, or a cache hit
+	StageParse     = "aio.parse"     // decoding and verifying the document
+	StageRetrieval = "aio.retrieval" // selecting, ranking and assembling the chunks
+)
+
+// observer passes one parse's events to the Parser's Metrics, Tracer
+// and StructuredLogger; each may be nil
+type observer struct {
+	metrics Metrics
+	tracer  Tracer
+	slog    *slog.Logger
+}
+
+// noSpan stands in for a span when there is no Tracer
+type noSpan struct{}
+
+func (noSpan) End(error, ...slog.Attr) {}
+
+func (o *observer) start(ctx context.Context, name string, attrs ...slog.Attr) TraceSpan {
+	if o == nil || o.tracer == nil {
+		return noSpan{}
+	}
+	_, span := o.tracer.Start(ctx, name, attrs...)
+	return span
+}
+
+func (o *observer) fetch(ev FetchEvent) {
+	if o == nil {
+		return
+	}
+	if o.metrics != nil {
+		o.metrics.Fetch(ev)
+	}
+	if o.slog != nil {
+		args := []any{"url", ev.URL, "status", ev.Status, "bytes", ev.Bytes, "cache", ev.Cache, "duration", ev.Duration}
+		if ev.Err != nil {
+			args = append(args, "error", ev.Err)
+		}
+		o.slog.Debug("aio fetch", args...)
+	}
+}
+
+func (o *observer) fallback(ev FallbackEvent) {
+	if o == nil {
+		return
+	}
+	if o.metrics != nil {
+		o.metrics.Fallback(ev)
+	}
+	if o.slog != nil {
+		o.slog.Debug("aio fallback", "url", ev.URL, "strategy", ev.Strategy, "error", ev.Err)
+	}
+}
+
+// newObserver is nil when the Parser observes nothing
+func (p *Parser) newObserver() *observer {
+	if p.Metrics == nil && p.Tracer == nil && p.StructuredLogger == nil {
+		return nil
+	}
+	return &observer{metrics: p.Metrics, tracer: p.Tracer, slog: p.StructuredLogger}
+}
+
+// parsed reports a parse of url begun at started, which produced env or
+// failed with err
+func (p *Parser) parsed(url string, started time.Time, env *ContentEnvelope, err error) {
+	if p.Metrics == nil && p.StructuredLogger == nil {
+		return
+	}
+	url, _ = stripCredentials(url)
+	ev := ParseEvent{URL: url, Duration: time.Since(started), Err: err}
+	if env != nil {
+		ev.Source = env.Source
+		ev.Selected = len(env.Items)
+		if env.Stats != nil {
+			ev.Chunks = env.Stats.TotalChunks
+		}
+	}
+	if p.Metrics != nil {
+		p.Metrics.Parse(ev)
+	}
+	if l := p.StructuredLogger; l != nil {
+		if err != nil {
+			l.Warn("aio parse failed", "url", url, "kind", errorKind(err), "duration", ev.Duration, "error", err)
+		} else {
+			l.Info("aio parse", "url", url, "source", ev.Source, "chunks", ev.Chunks,
+				"selected", ev.Selected, "duration", ev.Duration)
+		}
+	}
+}
+
+// logf writes a diagnostic to the Logger and, at Warn level, the
+// StructuredLogger
+func (p *Parser) logf(format string, args ...any) {
+	if p.Logger != nil {
+		p.Logger.Printf(format, args...)
+	}
+	if p.StructuredLogger != nil {
+		p.StructuredLogger.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// errorKind classifies a parse error for metrics labels and logs
+func errorKind(err error) string {
+	var rl *RateLimitError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &rl):
+		return "rate_limited"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrDisallowed):
+		return "disallowed"
+	case errors.Is(err, ErrPrivateAddress):
+		return "private_address"
+	case errors.Is(err, ErrTooLarge):
+		return "too_large"
+	case errors.Is(err, ErrInvalidSignature), errors.Is(err, ErrHashMismatch):
+		return "integrity"
+	case errors.Is(err, ErrUnsupportedVersion):
+		return "unsupported_version"
+	case errors.Is(err, ErrMalformed):
+		return "malformed"
+	case errors.Is(err, ErrNoSource):
+		return "no_source"
+	}
+	return "other"
+}
+
+// durationBuckets are the upper bounds, in seconds, of the duration
+// histograms PrometheusMetrics exposes
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics is a Metrics that serves its counters in the
+// Prometheus text exposition format, to be mounted at /metrics; NewHandler
+// does so when it is the Parser's Metrics. The zero value is ready to use.
+//
+//	aio_fetches_total{cache}            fetches by CacheStatus
+//	aio_fetch_errors_total              fetches that failed
+//	aio_fetch_bytes_total               bytes fetched from the network
+//	aio_fetch_duration_seconds          histogram of fetch durations
+//	aio_fallbacks_total{strategy}       source strategies that failed
+//	aio_parses_total{source}            parses by the strategy that found the document
+//	aio_parse_errors_total{kind}        failed parses by kind of error
+//	aio_parse_duration_seconds          histogram of parse durations
+//	aio_chunks_total                    chunks in the documents parsed
+//	aio_chunks_selected_total           chunks selected from them
+type PrometheusMetrics struct {
+	mu            sync.Mutex
+	fetches       map[string]uint64
+	fetchErrors   uint64
+	fetchBytes    uint64
+	fetchDuration histogram
+	fallbacks     map[string]uint64
+	parses        map[string]uint64
+	parseErrors   map[string]uint64
+	parseDuration histogram
+	chunks        uint64
+	selected      uint64
+}
+
+type histogram struct {
+	counts []uint64 // per bucket of durationBuckets, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	s := d.Seconds()
+	h.count++
+	h.sum += s
+	for i, le := range durationBuckets {
+		if s <= le {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+func inc(m *map[string]uint64, key string, n uint64) {
+	if *m == nil {
+		*m = map[string]uint64{}
+	}
+	(*m)[key] += n
+}
+
+func (m *PrometheusMetrics) Fetch(ev FetchEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ev.Err != nil {
+		m.fetchErrors++
+	} else {
+		inc(&m.fetches, string(ev.Cache), 1)
+		if ev.Cache == CacheMiss {
+			m.fetchBytes += uint64(ev.Bytes)
+		}
+	}
+	m.fetchDuration.observe(ev.Duration)
+}
+
+func (m *PrometheusMetrics) Fallback(ev FallbackEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inc(&m.fallbacks, string(ev.Strategy), 1)
+}
+
+func (m *PrometheusMetrics) Parse(ev ParseEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ev.Err != nil {
+		inc(&m.parseErrors, errorKind(ev.Err), 1)
+	} else {
+		inc(&m.parses, string(ev.Source), 1)
+		m.chunks += uint64(ev.Chunks)
+		m.selected += uint64(ev.Selected)
+	}
+	m.parseDuration.observe(ev.Duration)
+}
+
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(m.exposition()))
+}
+
+// exposition writes the metrics in the text format
+func (m *PrometheusMetrics) exposition() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	counter := func(name, help string, v uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	labelled := func(name, help, label string, values map[string]uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s{%s=%s} %d\n", name, label, strconv.Quote(k), values[k])
+		}
+	}
+	hist := func(name, help string, h histogram) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+		var cumulative uint64
+		for i, le := range durationBuckets {
+			if h.counts != nil {
+				cumulative += h.counts[i]
+			}
+			fmt.Fprintf(&b, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n", name, h.count,
+			name, strconv.FormatFloat(h.sum, 'g', -1, 64), name, h.count)
+	}
+
+	labelled("aio_fetches_total", "Documents and pages fetched, by cache status.", "cache", m.fetches)
+	counter("aio_fetch_errors_total", "Fetches that failed.", m.fetchErrors)
+	counter("aio_fetch_bytes_total", "Bytes fetched from the network, after decoding.", m.fetchBytes)
+	hist("aio_fetch_duration_seconds", "How long fetches took.", m.fetchDuration)
+	labelled("aio_fallbacks_total", "Source strategies that failed to find a document.", "strategy", m.fallbacks)
+	labelled("aio_parses_total", "Successful parses, by the strategy that found the document.", "source", m.parses)
+	labelled("aio_parse_errors_total", "Failed parses, by kind of error.", "kind", m.parseErrors)
+	hist("aio_parse_duration_seconds", "How long parses took.", m.parseDuration)
+	counter("aio_chunks_total", "Chunks in the documents parsed.", m.chunks)
+	counter("aio_chunks_selected_total", "Chunks selected from the documents parsed.", m.selected)
+	return b.String()
+}
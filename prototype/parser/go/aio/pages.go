@@ -0,0 +1,115 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// DefaultMaxPages bounds pagination when Options.MaxPages is zero
+const DefaultMaxPages = 20
+
+// PageError records a paginated page that could not be fetched or decoded
+type PageError struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// pageResult summarizes a pagination walk
+type pageResult struct {
+	verified bool        // every page's signature verified
+	failed   []PageError // pages skipped under PartialResult
+	limitHit bool        // stopped early by MaxTotalBytes
+}
+
+// followPages fetches the pages linked from aio through "next" and merges
+// their content and index into aio
+func followPages(aio *AIOFile, pageURL string, s *session, verified bool) (pageResult, error) {
+	opts := s.opts
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	result := pageResult{verified: verified}
+	seen := map[string]bool{pageURL: true}
+	next := aio.Next
+	for pages := 1; next != "" && pages < maxPages; pages++ {
+		nextURL, err := resolveReference(pageURL, next)
+		if err != nil {
+			return result, fmt.Errorf("page %d: bad next link %q: %w", pages+1, next, err)
+		}
+		if seen[nextURL] {
+			break
+		}
+		seen[nextURL] = true
+
+		limit, total, exhausted := downloadLimit(s)
+		if exhausted {
+			result.limitHit = true
+			break
+		}
+		page, pageVerified, err := fetchPage(s, nextURL, limit)
+		if total && errors.Is(err, ErrTooLarge) {
+			result.limitHit = true
+			break
+		}
+		if err != nil {
+			if !opts.PartialResult {
+				return result, fmt.Errorf("page %s: %w", nextURL, err)
+			}
+			// Without this page we also lose its next link, so stop here
+			result.failed = append(result.failed, PageError{URL: nextURL, Error: err.Error()})
+			break
+		}
+
+		aio.Content = append(aio.Content, page.Content...)
+		aio.Index = append(aio.Index, page.Index...)
+		result.verified = result.verified && pageVerified
+		s.run.progress.PagesFollowed++
+		s.run.report(PhasePage)
+		pageURL, next = nextURL, page.Next
+	}
+	return result, nil
+}
+
+// downloadLimit is the byte limit of the session's next download. The
+// aggregate budget of Options.MaxTotalBytes narrows Options.MaxBytes, so
+// an oversized page is cut off rather than downloaded in full; total
+// reports that it did, and exhausted that the budget is spent.
+func downloadLimit(s *session) (limit int64, total, exhausted bool) {
+	limit = s.opts.MaxBytes
+	if s.opts.MaxTotalBytes > 0 {
+		remaining := s.opts.MaxTotalBytes - s.run.progress.BytesDownloaded
+		if remaining <= 0 {
+			return 0, false, true
+		}
+		if limit <= 0 || remaining < limit {
+			limit, total = remaining, true
+		}
+	}
+	return limit, total, false
+}
+
+// fetchPage downloads and decodes a single page of a paginated document,
+// limited to limit bytes
+func fetchPage(s *session, pageURL string, limit int64) (*AIOFile, bool, error) {
+	body, _, err := s.fetchLimited(pageURL, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	return decodeAIO(body, s.opts)
+}
+
+// resolveReference resolves ref, which may be relative, against base
+func resolveReference(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
@@ -0,0 +1,250 @@
+package aio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Parser holds the configuration shared by many parses. The zero value is
+// ready to use and behaves like the package-level Parse. A Parser is safe
+// for concurrent use as long as its fields are not modified.
+type Parser struct {
+	// Client performs the HTTP requests. When nil, each parse builds a
+	// client from Options.Timeout, Options.MaxRedirects, Options.Cookies
+	// and Options.CookieJar; a supplied client is used as is and those
+	// options are ignored, except that unless AllowPrivateNetworks is set
+	// it follows redirects through a copy checking each target.
+	Client *http.Client
+
+	// Tokenizer counts the tokens of a text for budgets and envelope
+	// totals, e.g. ApproxCL100K or a vocabulary loaded with
+	// NewBPETokenizer. When nil, a heuristic for the document's language
+	// is used.
+	Tokenizer Tokenizer
+
+	// Logger receives diagnostics about recoverable failures, such as a
+	// page that could not be scraped. Nil discards them.
+	Logger *log.Logger
+
+	// StructuredLogger, when set, also receives the Logger's diagnostics,
+	// at Warn level, and logs each parse at Info and each fetch and
+	// fallback at Debug, with their details as attributes
+	StructuredLogger *slog.Logger
+
+	// Metrics counts fetches, fallbacks and parses, e.g. a
+	// PrometheusMetrics; Tracer starts a span around each stage of a
+	// parse. Nil observes nothing.
+	Metrics Metrics
+	Tracer  Tracer
+
+	// Options are applied to every parse made with this Parser
+	Options Options
+}
+
+// defaultParser backs the package-level Parse
+var defaultParser = &Parser{}
+
+// Parse fetches AIO content from url and selects the chunks matching query
+func (p *Parser) Parse(url string, query string) (*ContentEnvelope, error) {
+	return p.ParseContext(context.Background(), url, query)
+}
+
+// ParseContext is Parse with a context that bounds every request it makes.
+// With Options.Mirrors, each mirror is tried in turn after url fails. With
+// Options.WebhookURL, the envelope is also posted there in the background.
+func (p *Parser) ParseContext(ctx context.Context, url string, query string) (*ContentEnvelope, error) {
+	started := time.Now()
+	doc, err := p.open(ctx, url)
+	if err != nil {
+		p.parsed(url, started, nil, err)
+		return nil, err
+	}
+	defer doc.close()
+	env, err := doc.envelope(query, p.Options)
+	p.parsed(url, started, env, err)
+	if err != nil {
+		return nil, err
+	}
+	p.notify(env)
+	return env, nil
+}
+
+// document is a site's content fetched and decoded, ready for selection
+type document struct {
+	url      string
+	aio      *AIOFile
+	verified bool
+	src      *located
+	pages    pageResult
+	robots   *RobotsReport
+	run      *parseRun
+
+	// sess fetches the children of an index document, which are kept in
+	// children once downloaded
+	sess     *session
+	children map[string]*fetchedDocument
+
+	// tracker compares the content with the previous parse, once however
+	// many queries the document answers (Options.TrackChanges)
+	tracker *changeTracker
+}
+
+// open loads the content at url, or at the first of its mirrors to serve
+// it
+func (p *Parser) open(ctx context.Context, url string) (*document, error) {
+	if len(p.Options.Mirrors) == 0 {
+		return p.load(ctx, url)
+	}
+	return p.loadMirrors(ctx, url)
+}
+
+// load fetches and decodes the content of a single base URL. The
+// document holds its session open until closed.
+func (p *Parser) load(ctx context.Context, url string) (doc *document, err error) {
+	l := loaderFor(url)
+	if l != nil && l != SourceLoader(defaultHTTPLoader) {
+		return p.loadWith(ctx, l, url)
+	}
+	if scheme, _, ok := strings.Cut(url, "://"); ok && l == nil {
+		return nil, fmt.Errorf("%w %q", ErrNoLoader, scheme)
+	}
+	opts := p.Options
+	run := p.newRun(ctx)
+	timing := run.timing
+	started := time.Now()
+
+	url, urlAuth := stripCredentials(url)
+	url = normalizeURL(url)
+	sess, err := newSession(ctx, opts, p.Client, url, urlAuth, run)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			sess.close()
+		}
+	}()
+	timing.Discovery = time.Since(started)
+	run.report(PhaseDiscovery)
+
+	// Every strategy of the chain downloads something, so the whole walk
+	// counts as network time
+	started = time.Now()
+	span := run.obs.start(ctx, StageDiscovery, slog.String("url", url))
+	src, err := locate(sess, url, opts.FallbackChain)
+	timing.Network = time.Since(started)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	span.End(nil, slog.String("source", string(src.strategy)), slog.String("document_url", src.url))
+	aio, verified := src.doc, false
+	if aio == nil {
+		started = time.Now()
+		span := run.obs.start(ctx, StageParse, slog.String("url", src.url), slog.Int("bytes", len(src.body)))
+		aio, verified, err = decodeAIO(src.body, opts)
+		timing.Decode = time.Since(started)
+		if err != nil {
+			span.End(err)
+			return nil, err
+		}
+		span.End(nil, slog.Int("chunks", len(aio.Content)), slog.Bool("verified", verified))
+	}
+	run.report(PhaseDecode)
+
+	var pages pageResult
+	if opts.FollowNext {
+		// Later pages are decoded as they arrive, so their decode time is
+		// counted as network time
+		started = time.Now()
+		pages, err = followPages(aio, src.url, sess, verified)
+		timing.Network += time.Since(started)
+		if err != nil {
+			return nil, err
+		}
+		verified = pages.verified
+	}
+
+	if opts.MergeScraped && src.strategy != StrategyScrape {
+		// Gap filling is best effort: a page that cannot be fetched or
+		// parsed leaves the AIO content as it is
+		page, err := sess.fetch(url)
+		if err == nil {
+			var scraped []Chunk
+			if scraped, err = extract(opts, url, page); err == nil {
+				aio.Content = mergeScraped(aio.Content, scraped)
+			}
+		}
+		if err != nil {
+			run.logf("aio: not merging scraped content from %s: %v", url, err)
+		}
+	}
+
+	return &document{url: url, aio: aio, verified: verified, src: src, pages: pages, robots: sess.robots(src.url), run: run, sess: sess}, nil
+}
+
+// close releases the document's session, once no more envelopes or
+// children are needed from it
+func (d *document) close() {
+	if d.sess != nil {
+		d.sess.close()
+	}
+}
+
+// envelope selects and assembles the content matching query, first
+// merging the child documents the query needs from an index document
+func (d *document) envelope(query string, opts Options) (*ContentEnvelope, error) {
+	aio, children, err := d.follow(query)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TrackChanges && d.tracker == nil {
+		cache := cacheFor(opts)
+		if d.sess != nil {
+			cache = d.sess.cache
+		}
+		d.tracker = trackChanges(aio, d.src.url, cache, time.Now())
+	}
+	if d.tracker != nil && opts.ChangedOnly {
+		opts.Filter = d.tracker.filter(opts.Filter)
+	}
+	span := d.run.obs.start(d.run.ctx, StageRetrieval, slog.String("query", query))
+	env, err := assembleEnvelope(aio, d.url, query, opts, children.verified, d.run)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	span.End(nil, slog.Int("chunks", len(aio.Content)), slog.Int("selected", len(env.Items)))
+	if d.tracker != nil {
+		env.Changes = d.tracker.set
+		d.tracker.annotate(env.Items)
+	}
+	if opts.KeepRaw {
+		env.Raw = d.src.body
+	}
+	env.Source = d.src.strategy
+	env.DocumentURL = d.src.url
+	env.FailedPages = d.pages.failed
+	env.FailedDocuments = children.failed
+	env.TotalBytesLimitHit = d.pages.limitHit || children.limitHit
+	env.Robots = d.robots
+	return env, nil
+}
+
+// newRun starts the bookkeeping of one parse under ctx with this Parser's
+// tokenizer, loggers and observers
+func (p *Parser) newRun(ctx context.Context) *parseRun {
+	run := newParseRun(p.Options)
+	run.ctx = ctx
+	if p.Tokenizer != nil {
+		run.tokenizer = p.Tokenizer.Count
+	}
+	run.logger = p.Logger
+	run.obs = p.newObserver()
+	return run
+}
@@ -0,0 +1,70 @@
+package aio
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Phase names a stage of a parse reported to Options.OnProgress
+type Phase string
+
+const (
+	PhaseDiscovery Phase = "discovery" // the document URL is known
+	PhaseFetch     Phase = "fetch"     // a document or page was downloaded
+	PhasePage      Phase = "page"      // a paginated page was merged
+	PhaseDocument  Phase = "document"  // a child of an index document was merged
+	PhaseDecode    Phase = "decode"    // the first document was decoded
+	PhaseChunk     Phase = "chunk"     // one chunk went through selection
+	PhaseSelection Phase = "selection" // selection and ranking finished
+	PhaseDone      Phase = "done"      // the envelope is assembled
+)
+
+// ProgressEvent is a snapshot of a parse in progress. The counters are
+// cumulative over the whole parse.
+type ProgressEvent struct {
+	Phase           Phase
+	BytesDownloaded int64
+	ChunksProcessed int
+	PagesFollowed   int
+
+	// DocumentsFollowed counts the children of an index document merged
+	DocumentsFollowed int
+}
+
+// parseRun is the bookkeeping of a single parse: phase timing, the
+// progress counters, and the Parser settings that outlive Options
+type parseRun struct {
+	ctx        context.Context // bounds a Retriever's calls
+	timing     *Timing
+	onProgress func(ProgressEvent)
+	progress   ProgressEvent
+	tokenizer  tokenEstimator
+	logger     *log.Logger
+	obs        *observer
+}
+
+func newParseRun(opts Options) *parseRun {
+	return &parseRun{ctx: context.Background(), timing: &Timing{}, onProgress: opts.OnProgress}
+}
+
+// report passes a copy of the counters to the progress callback, if any.
+// It runs synchronously, so callbacks that feed a UI should hand the
+// event off rather than block.
+func (r *parseRun) report(phase Phase) {
+	if r.onProgress == nil {
+		return
+	}
+	r.progress.Phase = phase
+	r.onProgress(r.progress)
+}
+
+// logf writes to the Parser's loggers, if any
+func (r *parseRun) logf(format string, args ...any) {
+	if r.logger != nil {
+		r.logger.Printf(format, args...)
+	}
+	if r.obs != nil && r.obs.slog != nil {
+		r.obs.slog.Warn(fmt.Sprintf(format, args...))
+	}
+}
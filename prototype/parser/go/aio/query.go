@@ -0,0 +1,101 @@
+package aio
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// queryTerm is one lower-cased term of a query. Terms containing * or ?
+// are wildcards matched against whole words; other terms match as
+// substrings, by their stem under Options.Stemming. boost multiplies the
+// term's contribution to the score.
+type queryTerm struct {
+	text  string
+	stem  string
+	glob  bool
+	boost float64
+}
+
+// parseTerms splits a query into lower-cased terms. A term may carry a
+// boost suffix, as in "pricing^3"; a suffix that is not a positive number
+// is kept as part of the term.
+func parseTerms(query string) []queryTerm {
+	var terms []queryTerm
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		text, boost := field, 1.0
+		if i := strings.LastIndexByte(field, '^'); i > 0 {
+			if b, err := strconv.ParseFloat(field[i+1:], 64); err == nil && b > 0 {
+				text, boost = field[:i], b
+			}
+		}
+		terms = append(terms, queryTerm{
+			text:  text,
+			glob:  strings.ContainsAny(text, "*?"),
+			boost: boost,
+		})
+	}
+	return terms
+}
+
+// plainTerms returns the text of the non-wildcard terms
+func plainTerms(terms []queryTerm) []string {
+	var plain []string
+	for _, t := range terms {
+		if !t.glob {
+			plain = append(plain, t.text)
+		}
+	}
+	return plain
+}
+
+// matches reports whether the term occurs in s, which must already be
+// lower-cased
+func (t queryTerm) matches(s string) bool {
+	if t.stem != "" {
+		return strings.Contains(s, t.stem)
+	}
+	if !t.glob {
+		return strings.Contains(s, t.text)
+	}
+	for _, word := range strings.FieldsFunc(s, isWordSeparator) {
+		if globMatch(t.text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '*' && r != '?'
+}
+
+// globMatch reports whether name matches pattern, where * matches any run
+// of runes and ? matches exactly one
+func globMatch(pattern, name string) bool {
+	p, n := []rune(pattern), []rune(name)
+	// Backtrack to the most recent star on mismatch; linear for the
+	// common single-star case and never exponential.
+	pi, ni := 0, 0
+	star, mark := -1, 0
+	for ni < len(n) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == n[ni]):
+			pi++
+			ni++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ni
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			ni = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,138 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateLimited matches, through errors.Is, the RateLimitError of a
+// request refused with 429 Too Many Requests
+var ErrRateLimited = errors.New("aio: rate limited")
+
+// RateLimitError reports a 429 response and how long the server asked
+// callers to wait before trying again
+type RateLimitError struct {
+	URL string
+
+	// RetryAfter is the server's Retry-After delay, or zero when it sent
+	// none
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("GET %s: %v, retry after %s", e.URL, ErrRateLimited, e.RetryAfter)
+	}
+	return fmt.Sprintf("GET %s: %v", e.URL, ErrRateLimited)
+}
+
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// Waiting out a 429 without a Retry-After uses defaultRetryAfter; longer
+// delays than maxRateLimitWait are reported rather than slept through.
+// Retries of other failures wait retryBackoff, doubling each time.
+const (
+	defaultRetryAfter = time.Second
+	maxRateLimitWait  = time.Minute
+	retryBackoff      = 250 * time.Millisecond
+)
+
+// do sends req, retrying network errors and 5xx responses up to
+// Options.Retries times with exponential backoff, and waiting out 429
+// responses up to Options.RateLimitRetries times, as long as each wait
+// ends before the context's deadline
+func (s *session) do(req *http.Request) (*http.Response, error) {
+	if s.preflight {
+		if err := s.guard.check(s.ctx, req); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, err)
+		}
+	}
+	if err := s.authorize(req); err != nil {
+		return nil, err
+	}
+	failures, backoff := 0, retryBackoff
+	for attempt := 0; ; {
+		resp, err := s.client.Do(req)
+		if transient(resp, err) && s.ctx.Err() == nil && failures < s.opts.Retries {
+			if err == nil {
+				resp.Body.Close()
+				err = fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+			}
+			failures++
+			s.run.logf("aio: retrying in %s: %v", backoff, err)
+			if !s.sleep(backoff) {
+				return nil, err
+			}
+			backoff *= 2
+			continue
+		}
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		resp.Body.Close()
+		rl := &RateLimitError{URL: req.URL.String(), RetryAfter: retryAfter(resp.Header, time.Now())}
+		if attempt >= s.opts.RateLimitRetries {
+			return nil, rl
+		}
+		attempt++
+		wait := rl.RetryAfter
+		if wait == 0 {
+			wait = defaultRetryAfter
+		}
+		if !s.sleep(wait) {
+			return nil, rl
+		}
+	}
+}
+
+// transient reports whether a request may succeed if repeated: it failed
+// without a response, or the server answered with a 5xx error
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// sleep pauses for d, returning false without waiting when d is longer
+// than maxRateLimitWait or outlasts the context, and early if the context
+// is cancelled
+func (s *session) sleep(d time.Duration) bool {
+	if d > maxRateLimitWait {
+		return false
+	}
+	if deadline, ok := s.ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as
+// an HTTP date, returning zero when it is absent or malformed
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrUnresolvedRef is returned under Options.StrictRefs when a chunk
+// reference names a missing chunk or forms a cycle
+var ErrUnresolvedRef = errors.New("aio: unresolved chunk reference")
+
+// refPattern matches a transclusion such as {{ref:pricing}}
+var refPattern = regexp.MustCompile(`\{\{ref:([^{}\s]+)\}\}`)
+
+// Limits of reference expansion. A chunk referencing another twice, which
+// references a third twice, and so on, doubles its size at every level,
+// so a small document could otherwise expand to gigabytes.
+const (
+	// maxRefDepth is how deeply references may nest; deeper ones are left
+	// as written, as an unresolvable reference is
+	maxRefDepth = 16
+
+	// defaultMaxExpandedBytes caps a chunk's expanded content when
+	// Options.MaxChunkBytes does not
+	defaultMaxExpandedBytes = 1 << 20
+)
+
+// refResolver expands chunk references against every chunk of a document,
+// memoizing expansions and tracking the chunks being expanded to detect
+// cycles. An expansion growing past limit bytes fails the whole
+// resolution with ErrTooLarge.
+type refResolver struct {
+	chunks map[string]string
+	done   map[string]string
+	active map[string]bool
+	limit  int
+	err    error
+
+	// failed memoizes the best-effort expansions that hit a cycle or the
+	// depth limit, for the chunk being resolved only, so each is worked
+	// out once however often it is referenced
+	failed map[string]refFailure
+}
+
+type refFailure struct {
+	text string
+	err  error
+}
+
+func newRefResolver(content []Chunk, limit int) *refResolver {
+	chunks := make(map[string]string, len(content))
+	for _, chunk := range content {
+		chunks[chunk.ID] = chunk.Content
+	}
+	if limit <= 0 {
+		limit = defaultMaxExpandedBytes
+	}
+	return &refResolver{
+		chunks: chunks,
+		done:   make(map[string]string),
+		active: make(map[string]bool),
+		limit:  limit,
+		failed: make(map[string]refFailure),
+	}
+}
+
+// resolveRefs expands the references in each chunk's content, to at most
+// limit bytes (defaultMaxExpandedBytes when zero) and ErrTooLarge beyond.
+// Unless strict, unresolvable references are left in place.
+func resolveRefs(chunks []Chunk, all []Chunk, strict bool, limit int) ([]Chunk, error) {
+	r := newRefResolver(all, limit)
+	for i := range chunks {
+		if !refPattern.MatchString(chunks[i].Content) {
+			continue
+		}
+		r.active[chunks[i].ID] = true
+		clear(r.failed)
+		content, err := r.expand(chunks[i].Content)
+		delete(r.active, chunks[i].ID)
+		if r.err != nil {
+			return nil, fmt.Errorf("chunk %q: %w", chunks[i].ID, r.err)
+		}
+		if err != nil && strict {
+			return nil, fmt.Errorf("chunk %q: %w", chunks[i].ID, err)
+		}
+		chunks[i].Content = content
+	}
+	return chunks, nil
+}
+
+// expand replaces every reference in content with the referenced chunk's
+// expansion. References that cannot be resolved are left as written and
+// the first failure is returned alongside the best-effort text.
+func (r *refResolver) expand(content string) (string, error) {
+	var firstErr error
+	size := len(content)
+	out := refPattern.ReplaceAllStringFunc(content, func(ref string) string {
+		if r.err != nil {
+			return ref
+		}
+		id := refPattern.FindStringSubmatch(ref)[1]
+		text, ok, err := r.resolve(id)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if !ok || r.err != nil {
+			return ref
+		}
+		if size += len(text) - len(ref); size > r.limit {
+			r.err = fmt.Errorf("%w: references expand to more than %d bytes", ErrTooLarge, r.limit)
+			return ref
+		}
+		return text
+	})
+	return out, firstErr
+}
+
+// resolve returns the expansion of chunk id; ok is false when the chunk is
+// missing or already being expanded. Only complete expansions are
+// memoized across chunks, so a cycle seen from one chunk does not leak
+// into another.
+func (r *refResolver) resolve(id string) (text string, ok bool, err error) {
+	if text, ok := r.done[id]; ok {
+		return text, true, nil
+	}
+	if f, ok := r.failed[id]; ok {
+		return f.text, true, f.err
+	}
+	if r.active[id] {
+		return "", false, fmt.Errorf("%w: cycle through %q", ErrUnresolvedRef, id)
+	}
+	if len(r.active) >= maxRefDepth {
+		return "", false, fmt.Errorf("%w: %q nested more than %d deep", ErrUnresolvedRef, id, maxRefDepth)
+	}
+	raw, ok := r.chunks[id]
+	if !ok {
+		return "", false, fmt.Errorf("%w: no chunk %q", ErrUnresolvedRef, id)
+	}
+
+	r.active[id] = true
+	text, err = r.expand(raw)
+	delete(r.active, id)
+	if err == nil {
+		r.done[id] = text
+	} else {
+		r.failed[id] = refFailure{text, err}
+	}
+	return text, true, err
+}
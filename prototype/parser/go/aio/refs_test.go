@@ -0,0 +1,115 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// doublingChain returns n chunks, each referencing the next twice, whose
+// full expansion is 2^(n-1) copies of the last, leaf
+func doublingChain(n int, leaf string) []Chunk {
+	chunks := make([]Chunk, n)
+	for i := range chunks {
+		chunks[i] = Chunk{ID: fmt.Sprintf("c%d", i), Content: fmt.Sprintf("{{ref:c%d}} {{ref:c%d}}", i+1, i+1)}
+	}
+	chunks[n-1].Content = leaf
+	return chunks
+}
+
+func TestResolveRefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  []Chunk
+		strict  bool
+		limit   int
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "nested",
+			chunks: []Chunk{{ID: "a", Content: "see {{ref:b}}"}, {ID: "b", Content: "[{{ref:c}}]"}, {ID: "c", Content: "text"}},
+			want:   "see [text]",
+		},
+		{
+			name:   "missing left as written",
+			chunks: []Chunk{{ID: "a", Content: "see {{ref:nope}}"}},
+			want:   "see {{ref:nope}}",
+		},
+		{
+			name:    "missing strict",
+			chunks:  []Chunk{{ID: "a", Content: "see {{ref:nope}}"}},
+			strict:  true,
+			wantErr: ErrUnresolvedRef,
+		},
+		{
+			name:    "cycle strict",
+			chunks:  []Chunk{{ID: "a", Content: "{{ref:b}}"}, {ID: "b", Content: "{{ref:a}}"}},
+			strict:  true,
+			wantErr: ErrUnresolvedRef,
+		},
+		{
+			name:   "doubling within limit",
+			chunks: doublingChain(4, "leaf"),
+			want:   strings.TrimSpace(strings.Repeat("leaf ", 8)),
+		},
+		{
+			name:    "doubling past the default limit",
+			chunks:  doublingChain(15, strings.Repeat("x", 100)),
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "doubling past MaxChunkBytes",
+			chunks:  doublingChain(10, "leaf"),
+			limit:   1024,
+			wantErr: ErrTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := []Chunk{tt.chunks[0]}
+			got, err := resolveRefs(selected, tt.chunks, tt.strict, tt.limit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want != "" && got[0].Content != tt.want {
+				t.Errorf("content = %q, want %q", got[0].Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRefsDepth(t *testing.T) {
+	var chunks []Chunk
+	for i := 0; i <= maxRefDepth+2; i++ {
+		chunks = append(chunks, Chunk{ID: fmt.Sprintf("c%d", i), Content: fmt.Sprintf("{{ref:c%d}}", i+1)})
+	}
+	chunks = append(chunks, Chunk{ID: fmt.Sprintf("c%d", maxRefDepth+3), Content: "leaf"})
+
+	if _, err := resolveRefs([]Chunk{chunks[0]}, chunks, true, 0); !errors.Is(err, ErrUnresolvedRef) {
+		t.Fatalf("strict: err = %v, want ErrUnresolvedRef", err)
+	}
+	got, err := resolveRefs([]Chunk{chunks[0]}, chunks, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refPattern.MatchString(got[0].Content) {
+		t.Errorf("content = %q, want the over-deep reference left as written", got[0].Content)
+	}
+}
+
+func TestParseDoublingRefs(t *testing.T) {
+	doc := AIOFile{Version: "2.1", Content: doublingChain(28, strings.Repeat("x", 100))}
+	data := mustEncode(t, &doc)
+	_, err := ParseReader(strings.NewReader(string(data)), "", Options{})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,62 @@
+package aio
+
+import (
+	"sort"
+	"strings"
+)
+
+// relatedChunks picks up to n candidates sharing index keywords with the
+// matched chunks, ordered by how many keywords they share, and marks them
+// Related. Candidates sharing none are never picked.
+func relatedChunks(matched, candidates []Chunk, keywords map[string][]string, n int) []Chunk {
+	shared := make(map[string]bool)
+	for _, chunk := range matched {
+		for _, k := range keywords[chunk.ID] {
+			shared[k] = true
+		}
+	}
+
+	type scored struct {
+		chunk   Chunk
+		overlap int
+	}
+	var picks []scored
+	for _, chunk := range candidates {
+		overlap := 0
+		for _, k := range keywords[chunk.ID] {
+			if shared[k] {
+				overlap++
+			}
+		}
+		if overlap > 0 {
+			picks = append(picks, scored{chunk, overlap})
+		}
+	}
+	sort.SliceStable(picks, func(i, j int) bool {
+		return picks[i].overlap > picks[j].overlap
+	})
+	if len(picks) > n {
+		picks = picks[:n]
+	}
+
+	related := make([]Chunk, len(picks))
+	for i, p := range picks {
+		related[i] = p.chunk
+		related[i].Related = true
+		related[i].Score = 0
+	}
+	return related
+}
+
+// chunkKeywords returns the lower-cased keywords of an index entry, which
+// may be nil
+func chunkKeywords(entry *IndexEntry) []string {
+	if entry == nil {
+		return nil
+	}
+	keywords := make([]string, len(entry.Keywords))
+	for i, k := range entry.Keywords {
+		keywords[i] = strings.ToLower(k)
+	}
+	return keywords
+}
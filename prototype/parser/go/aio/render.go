@@ -0,0 +1,131 @@
+package aio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Format is an output format ContentEnvelope.Render writes
+type Format string
+
+const (
+	// FormatMarkdown writes each chunk under a heading, followed by the
+	// source it was read from
+	FormatMarkdown Format = "md"
+
+	// FormatText writes the same as FormatMarkdown with the Markdown
+	// syntax removed, for prompts and terminals
+	FormatText Format = "text"
+
+	// FormatJSONL writes one chunk per line as JSON, each with its source
+	// URL, for ingestion pipelines
+	FormatJSONL Format = "jsonl"
+)
+
+// ErrUnknownFormat is returned for an output format Render does not write
+var ErrUnknownFormat = errors.New("aio: unknown output format")
+
+// ParseFormat reads a format name as given on a command line: "md" or
+// "markdown", "text", "txt" or "plain", and "jsonl" or "ndjson"
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "text", "txt", "plain":
+		return FormatText, nil
+	case "jsonl", "ndjson":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("%w %q (want md, text or jsonl)", ErrUnknownFormat, name)
+}
+
+// Render writes the envelope's selected chunks in format, in Items order.
+// Each chunk's source is its citation, or else the document it came from.
+func (e *ContentEnvelope) Render(format Format) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatMarkdown, FormatText:
+		for i, c := range e.Items {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			block := markdownBlock(c)
+			source := "*Source: <" + e.chunkSource(c) + ">*"
+			if format == FormatText {
+				block = stripMarkdown(block)
+				source = "Source: " + e.chunkSource(c)
+			}
+			fmt.Fprintf(&buf, "%s\n\n%s\n", block, source)
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		for _, c := range e.Items {
+			c.SourceURL = e.chunkSource(c)
+			if err := enc.Encode(c); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	return buf.Bytes(), nil
+}
+
+// chunkSource is where a chunk can be cited: its line citation, the
+// document it was merged from, or the document of the envelope
+func (e *ContentEnvelope) chunkSource(c Chunk) string {
+	switch {
+	case c.Citation != "":
+		return c.Citation
+	case c.SourceURL != "":
+		return c.SourceURL
+	case e.DocumentURL != "":
+		return e.DocumentURL
+	}
+	return e.SourceURL
+}
+
+// markdownBlock is a chunk's content under a level 2 heading of its
+// section, or ID, unless the content opens with a heading of its own
+func markdownBlock(c Chunk) string {
+	content := strings.TrimSpace(c.Content)
+	if strings.HasPrefix(content, "#") {
+		return content
+	}
+	heading := c.Section
+	if heading == "" {
+		heading = c.ID
+	}
+	return "## " + heading + "\n\n" + content
+}
+
+var (
+	mdHeading  = regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+`)
+	mdFence    = regexp.MustCompile("(?m)^[ \t]*(```|~~~).*$\n?")
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdEmphasis = regexp.MustCompile("(\\*\\*|__|\\*|~~|`)([^*_~`\n]+)(\\*\\*|__|\\*|~~|`)")
+	mdQuote    = regexp.MustCompile(`(?m)^ {0,3}>[ \t]?`)
+	mdRule     = regexp.MustCompile(`(?m)^ {0,3}([-*_][ \t]*){3,}$`)
+)
+
+// stripMarkdown reduces Markdown to its text: headings, quotes and rules
+// lose their markers, links and images keep their text, emphasis and
+// code spans are unwrapped and code fences dropped. Single underscores
+// are left alone, being more often part of a name than emphasis, and so
+// are list markers and tables, which read well enough as text.
+func stripMarkdown(s string) string {
+	s = mdFence.ReplaceAllString(s, "")
+	s = mdRule.ReplaceAllString(s, "")
+	s = mdHeading.ReplaceAllString(s, "")
+	s = mdQuote.ReplaceAllString(s, "")
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdEmphasis.ReplaceAllString(s, "$2")
+	return strings.TrimSpace(s)
+}
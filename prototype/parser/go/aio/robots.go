@@ -0,0 +1,255 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrDisallowed is returned for a request robots.txt does not permit
+var ErrDisallowed = errors.New("aio: disallowed by robots.txt")
+
+// DefaultRobotsAgents are the user-agent tokens of AI crawlers whose
+// robots.txt groups are honored alongside the parser's own when
+// Options.RobotsAgents is nil. Sites address these groups to keep AI
+// systems out, and an agent embedding the parser is one.
+var DefaultRobotsAgents = []string{
+	"GPTBot", "ChatGPT-User", "ClaudeBot", "CCBot", "Google-Extended", "PerplexityBot",
+}
+
+// robotsMaxBytes is how much of a robots.txt is read; RFC 9309 lets
+// crawlers ignore rules past 500 KiB
+const robotsMaxBytes = 500 << 10
+
+// RobotsReport records whether robots.txt let the parser read a URL, and
+// why
+type RobotsReport struct {
+	URL     string `json:"url"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// robotsFile is a site's parsed robots.txt. When unavailable is set the
+// file could not be fetched and every path is disallowed; when groups is
+// empty every path is allowed.
+type robotsFile struct {
+	groups      []robotsGroup
+	status      string // why the file has no rules, e.g. "no robots.txt (404 Not Found)"
+	unavailable bool
+}
+
+// robotsGroup is the rules following a run of User-agent lines
+type robotsGroup struct {
+	agents []string // lower case, "*" for the default group
+	rules  []robotsRule
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+func (r robotsRule) String() string {
+	if r.allow {
+		return "Allow: " + r.pattern
+	}
+	return "Disallow: " + r.pattern
+}
+
+// parseRobots reads the groups of a robots.txt as RFC 9309 describes.
+// Lines it does not know, such as Sitemap or AIO-Content, are skipped.
+func parseRobots(body string) *robotsFile {
+	f := &robotsFile{}
+	var cur *robotsGroup
+	inRules := false
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if cur == nil || inRules {
+				f.groups = append(f.groups, robotsGroup{})
+				cur = &f.groups[len(f.groups)-1]
+				inRules = false
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if cur == nil {
+				continue
+			}
+			inRules = true
+			if value != "" {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		}
+	}
+	return f
+}
+
+// rulesFor returns the rules of the groups naming agent, and whether any
+// does
+func (f *robotsFile) rulesFor(agent string) ([]robotsRule, bool) {
+	agent = strings.ToLower(agent)
+	var rules []robotsRule
+	found := false
+	for _, g := range f.groups {
+		for _, a := range g.agents {
+			if a == agent {
+				rules = append(rules, g.rules...)
+				found = true
+				break
+			}
+		}
+	}
+	return rules, found
+}
+
+// check decides whether path (with its query) may be fetched. The
+// parser's own token follows its group, or the * group when it has none;
+// each of the AI agent tokens counts only where a group names it. Any of
+// them disallowing the path disallows it.
+func (f *robotsFile) check(path, own string, agents []string) (bool, string) {
+	if f.unavailable {
+		return false, f.status + ", so every path is disallowed"
+	}
+	if path == "/robots.txt" {
+		return true, "robots.txt itself is always allowed"
+	}
+	for i, agent := range append([]string{own}, agents...) {
+		rules, found := f.rulesFor(agent)
+		group := agent
+		if !found && i == 0 {
+			rules, found = f.rulesFor("*")
+			group = "*"
+		}
+		if !found {
+			continue
+		}
+		if rule, ok := decide(rules, path); ok && !rule.allow {
+			return false, fmt.Sprintf("%q in the group for User-agent: %s", rule.String(), group)
+		}
+	}
+	if f.status != "" {
+		return true, f.status
+	}
+	return true, "no rule disallows " + path
+}
+
+// decide returns the rule governing path: the longest matching pattern,
+// with Allow winning a tie
+func decide(rules []robotsRule, path string) (robotsRule, bool) {
+	var best robotsRule
+	found := false
+	for _, r := range rules {
+		if !robotsMatch(r.pattern, path) {
+			continue
+		}
+		if !found || len(r.pattern) > len(best.pattern) || len(r.pattern) == len(best.pattern) && r.allow {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// robotsMatch matches a path against a rule pattern, where * matches any
+// run of characters and a trailing $ anchors the pattern at the end
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	matched, _ := regexp.MatchString(expr, path)
+	return matched
+}
+
+// robots decides whether the session may fetch rawURL, fetching the
+// site's robots.txt the first time one of its URLs is checked
+func (s *session) robots(rawURL string) *RobotsReport {
+	if s.opts.IgnoreRobots {
+		return &RobotsReport{URL: rawURL, Allowed: true, Reason: "robots.txt not checked (Options.IgnoreRobots)"}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return &RobotsReport{URL: rawURL, Allowed: true, Reason: "not a web URL"}
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	s.robotsMu.Lock()
+	f, ok := s.robotsFiles[origin]
+	s.robotsMu.Unlock()
+	if !ok {
+		f = s.fetchRobots(origin)
+		s.robotsMu.Lock()
+		if s.robotsFiles == nil {
+			s.robotsFiles = map[string]*robotsFile{}
+		}
+		s.robotsFiles[origin] = f
+		s.robotsMu.Unlock()
+	}
+
+	agents := s.opts.RobotsAgents
+	if agents == nil {
+		agents = DefaultRobotsAgents
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allowed, reason := f.check(path, productToken(s.header.Get("User-Agent")), agents)
+	return &RobotsReport{URL: rawURL, Allowed: allowed, Reason: reason}
+}
+
+// fetchRobots downloads and parses origin's robots.txt. As RFC 9309
+// requires, a 4xx answer allows everything and an unreachable file, a
+// 5xx answer included, disallows everything.
+func (s *session) fetchRobots(origin string) *robotsFile {
+	req, err := newRequest(s.ctx, origin+"/robots.txt", s.header)
+	if err != nil {
+		return &robotsFile{unavailable: true, status: "robots.txt unavailable (" + err.Error() + ")"}
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return &robotsFile{unavailable: true, status: "robots.txt unavailable (" + err.Error() + ")"}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+		if err != nil {
+			return &robotsFile{unavailable: true, status: "robots.txt unavailable (" + err.Error() + ")"}
+		}
+		return parseRobots(string(body))
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &robotsFile{status: "no robots.txt (" + resp.Status + ")"}
+	default:
+		return &robotsFile{unavailable: true, status: "robots.txt unavailable (" + resp.Status + ")"}
+	}
+}
+
+// productToken is the name a User-Agent string starts with, which
+// robots.txt groups match: "AIOParser-Go" for "AIOParser-Go/0.1 (...)"
+func productToken(ua string) string {
+	ua = strings.TrimSpace(ua)
+	if i := strings.IndexAny(ua, "/ "); i >= 0 {
+		return ua[:i]
+	}
+	return ua
+}
@@ -0,0 +1,38 @@
+package aio
+
+import "strings"
+
+// SchemePolicy controls which URL schemes discovery tries for a site
+type SchemePolicy int
+
+const (
+	// SchemeAsGiven only uses the scheme of the Parse URL
+	SchemeAsGiven SchemePolicy = iota
+
+	// SchemeUpgrade uses https:// in place of an http:// Parse URL, for
+	// sites that only publish their document over HTTPS. HTTPS URLs are
+	// never downgraded.
+	SchemeUpgrade
+
+	// SchemeBoth tries https:// first and then http://, whichever scheme
+	// the Parse URL has
+	SchemeBoth
+)
+
+// schemeVariants lists the URLs to try for pageURL under policy, in order.
+// URLs with other schemes are returned as they are.
+func schemeVariants(pageURL string, policy SchemePolicy) []string {
+	scheme, rest, ok := strings.Cut(pageURL, "://")
+	scheme = strings.ToLower(scheme)
+	if !ok || (scheme != "http" && scheme != "https") {
+		return []string{pageURL}
+	}
+	switch policy {
+	case SchemeUpgrade:
+		return []string{"https://" + rest}
+	case SchemeBoth:
+		return []string{"https://" + rest, "http://" + rest}
+	default:
+		return []string{pageURL}
+	}
+}
@@ -0,0 +1,148 @@
+package aio
+
+import "sort"
+
+// FieldWeights weights a term match in each searchable chunk field
+type FieldWeights struct {
+	ID       float64
+	Keywords float64
+	Title    float64
+	Content  float64
+
+	// KeywordCoverage scales the bonus for matching a large share of a
+	// chunk's keywords: the score is multiplied by
+	// 1 + KeywordCoverage * matched/total, so one hit among fifty keywords
+	// counts for less than one among three. Zero disables it.
+	KeywordCoverage float64
+}
+
+// DefaultFieldWeights favours the author-curated index keywords over
+// incidental mentions in the content body
+var DefaultFieldWeights = FieldWeights{
+	ID:       2,
+	Keywords: 3,
+	Title:    2,
+	Content:  1,
+
+	KeywordCoverage: 1,
+}
+
+func (w FieldWeights) orDefault() FieldWeights {
+	if w == (FieldWeights{}) {
+		return DefaultFieldWeights
+	}
+	return w
+}
+
+// indexByID maps chunk IDs to their index entries
+func indexByID(index []IndexEntry) map[string]*IndexEntry {
+	entries := make(map[string]*IndexEntry, len(index))
+	for i := range index {
+		entries[index[i].ID] = &index[i]
+	}
+	return entries
+}
+
+// keywordsByID maps chunk IDs to the lower-cased keywords of their index
+// entries, so each keyword is lower-cased once per document rather than
+// once for every chunk scored and every chunk it is compared against. As
+// in indexByID, the last of duplicate entries wins.
+func keywordsByID(index []IndexEntry) map[string][]string {
+	keywords := make(map[string][]string, len(index))
+	for i := range index {
+		keywords[index[i].ID] = chunkKeywords(&index[i])
+	}
+	return keywords
+}
+
+// scoreChunk sums, for every query term, the weight of each field the
+// term occurs in times the term's boost, and returns the terms that
+// matched at least one field. entry may be nil for chunks missing from
+// the index; keywords are its lower-cased keywords, as chunkKeywords or
+// keywordsByID give them.
+func scoreChunk(chunk Chunk, entry *IndexEntry, keywords []string, terms []queryTerm, w FieldWeights) (float64, []string) {
+	lower := lowerFor(chunk.lang)
+	id := lower(chunk.ID)
+	content := lower(chunk.Content)
+	var title string
+	if entry != nil {
+		title = lower(entry.Title)
+	}
+
+	score := 0.0
+	var matched []string
+	hitKeywords := make([]bool, len(keywords))
+	for _, term := range terms {
+		termScore := 0.0
+		if term.matches(id) {
+			termScore += w.ID
+		}
+		keywordHit := false
+		for i, k := range keywords {
+			if term.matches(k) {
+				hitKeywords[i] = true
+				keywordHit = true
+			}
+		}
+		if keywordHit {
+			termScore += w.Keywords
+		}
+		if title != "" && term.matches(title) {
+			termScore += w.Title
+		}
+		if term.matches(content) {
+			termScore += w.Content
+		}
+		if termScore > 0 {
+			matched = append(matched, term.text)
+		}
+		score += termScore * term.boost
+	}
+	if len(keywords) > 0 && w.KeywordCoverage != 0 {
+		hits := 0
+		for _, hit := range hitKeywords {
+			if hit {
+				hits++
+			}
+		}
+		score *= 1 + w.KeywordCoverage*float64(hits)/float64(len(keywords))
+	}
+	return score, matched
+}
+
+// TieBreaker orders two chunks of equal score, reporting whether a goes
+// first (Options.TieBreak)
+type TieBreaker func(a, b Chunk) bool
+
+// Built-in tie breakers. ByDocumentOrder is the default.
+var (
+	ByDocumentOrder TieBreaker = func(a, b Chunk) bool { return a.pos < b.pos }
+	ByChunkID       TieBreaker = func(a, b Chunk) bool { return a.ID < b.ID }
+	ByContentLength TieBreaker = func(a, b Chunk) bool { return len(a.Content) < len(b.Content) }
+)
+
+// rankChunks orders chunks by descending score, using tie (document order
+// when nil) between equal scores. The sort is stable, so chunks the tie
+// breaker considers equal also keep document order.
+func rankChunks(chunks []Chunk, tie TieBreaker) {
+	if tie == nil {
+		tie = ByDocumentOrder
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if chunks[i].Score != chunks[j].Score {
+			return chunks[i].Score > chunks[j].Score
+		}
+		return tie(chunks[i], chunks[j])
+	})
+}
+
+// groupByKeyword lists chunks under each query term they matched
+func groupByKeyword(chunks []Chunk) map[string][]Chunk {
+	groups := make(map[string][]Chunk)
+	for _, c := range chunks {
+		for _, term := range c.matchedTerms {
+			groups[term] = append(groups[term], c)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,42 @@
+package aio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func FuzzScore(f *testing.F) {
+	f.Add("Pricing is per seat, billed monthly.", "pricing, plans", "pricing plans")
+	f.Add("", "", "")
+	f.Add("IŞIK", "ışık", `"exact phrase" pric* -excluded`)
+	f.Fuzz(func(t *testing.T, content, keywords, query string) {
+		entry := &IndexEntry{ID: "c", Title: content, Keywords: strings.Split(keywords, ",")}
+		chunk := Chunk{ID: "c", Content: content}
+		terms := queryTerms(strings.ToLower(query), Options{})
+		score, matched := scoreChunk(chunk, entry, chunkKeywords(entry), terms, DefaultFieldWeights)
+		if math.IsNaN(score) || math.IsInf(score, 0) {
+			t.Fatalf("score = %v", score)
+		}
+		if score == 0 && len(matched) > 0 {
+			t.Fatalf("matched %q with a zero score", matched)
+		}
+	})
+}
+
+func BenchmarkParseScaling(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("chunks=%d", n), func(b *testing.B) {
+			data := scalingDocument(b, n)
+			opts := Options{MaxTokens: 2000, Summarizer: ExtractiveSummarizer{}}
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseReader(strings.NewReader(string(data)), "pricing topic7 plnas", opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
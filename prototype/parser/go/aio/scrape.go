@@ -0,0 +1,221 @@
+package aio
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// noiseTags are dropped entirely when scraping, along with their subtrees
+var noiseTags = map[string]bool{
+	"nav": true, "header": true, "footer": true, "aside": true,
+	"script": true, "style": true, "noscript": true, "iframe": true,
+	"form": true, "button": true, "svg": true, "canvas": true,
+	"video": true, "audio": true, "object": true, "embed": true,
+	"template": true,
+}
+
+// blockTags end the current paragraph of scraped text
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"li": true, "ul": true, "ol": true, "table": true, "tr": true,
+	"blockquote": true, "pre": true, "br": true, "dd": true, "dt": true,
+}
+
+// Extractor turns an HTML page into content chunks, for StrategyScrape and
+// Options.MergeScraped. Set Options.Extractor to swap in other heuristics,
+// such as site-specific selectors or a readability port.
+type Extractor interface {
+	Extract(pageURL string, page []byte) ([]Chunk, error)
+}
+
+// ExtractorFunc adapts a function to Extractor
+type ExtractorFunc func(pageURL string, page []byte) ([]Chunk, error)
+
+func (f ExtractorFunc) Extract(pageURL string, page []byte) ([]Chunk, error) {
+	return f(pageURL, page)
+}
+
+// DefaultExtractor is used when Options.Extractor is nil. It drops
+// navigation, headers, footers, scripts and other boilerplate and returns
+// one chunk per heading-delimited section of the page's main content.
+var DefaultExtractor Extractor = ExtractorFunc(func(_ string, page []byte) ([]Chunk, error) {
+	return scrapeChunks(page)
+})
+
+// extract runs the configured Extractor over page. Chunks it leaves
+// without an ID are numbered scraped-1, scraped-2, ... in order, empty
+// ones are dropped, and all are marked Scraped.
+func extract(opts Options, pageURL string, page []byte) ([]Chunk, error) {
+	x := opts.Extractor
+	if x == nil {
+		x = DefaultExtractor
+	}
+	chunks, err := x.Extract(pageURL, page)
+	if err != nil {
+		return nil, err
+	}
+	out := chunks[:0]
+	for _, c := range chunks {
+		if strings.TrimSpace(c.Content) == "" {
+			continue
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("scraped-%d", len(out)+1)
+		}
+		c.Scraped = true
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// scrapedIndex gives each scraped chunk under a heading an index entry
+// titled with it, so queries match section headings as they would the
+// titles of an AIO document
+func scrapedIndex(chunks []Chunk) []IndexEntry {
+	var index []IndexEntry
+	for _, c := range chunks {
+		if c.Section != "" {
+			index = append(index, IndexEntry{ID: c.ID, Title: c.Section})
+		}
+	}
+	return index
+}
+
+// scrapeChunks extracts the readable text of an HTML page as chunks, one
+// per heading-delimited section. Boilerplate elements are skipped; when
+// the page has a <main> or <article> only that element is read, and
+// otherwise only the <body>, leaving out the <title>.
+func scrapeChunks(page []byte) ([]Chunk, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	root := findElement(doc, "main")
+	if root == nil {
+		root = findElement(doc, "article")
+	}
+	if root == nil {
+		root = findElement(doc, "body")
+	}
+	if root == nil {
+		root = doc
+	}
+
+	s := &scraper{}
+	s.walk(root)
+	s.flush()
+	return s.chunks, nil
+}
+
+// scraper accumulates sections while walking the DOM
+type scraper struct {
+	chunks  []Chunk
+	section string
+	paras   []string
+	line    strings.Builder
+}
+
+func (s *scraper) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		s.line.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if noiseTags[n.Data] {
+			return
+		}
+		if isHeading(n.Data) {
+			s.flush()
+			s.section = collapseSpace(nodeText(n))
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.walk(c)
+	}
+	if n.Type == html.ElementNode && blockTags[n.Data] {
+		s.endParagraph()
+	}
+}
+
+func (s *scraper) endParagraph() {
+	if text := collapseSpace(s.line.String()); text != "" {
+		s.paras = append(s.paras, text)
+	}
+	s.line.Reset()
+}
+
+// flush closes the current section as a chunk
+func (s *scraper) flush() {
+	s.endParagraph()
+	if len(s.paras) > 0 {
+		s.chunks = append(s.chunks, Chunk{
+			ID:      fmt.Sprintf("scraped-%d", len(s.chunks)+1),
+			Section: s.section,
+			Content: strings.Join(s.paras, "\n\n"),
+			Scraped: true,
+		})
+	}
+	s.paras = nil
+}
+
+func isHeading(tag string) bool {
+	return len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6'
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type == html.ElementNode && n.Data == "br" {
+		return " "
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// mergeScraped appends the scraped chunks whose content is not already in
+// content. A scraped chunk is a duplicate when the SHA-256 of its
+// whitespace-normalized text matches an AIO chunk's published hash or the
+// hash of that chunk's own normalized content.
+func mergeScraped(content, scraped []Chunk) []Chunk {
+	seen := make(map[string]bool, len(content)*2)
+	for _, c := range content {
+		if _, digest, err := parseHash(c.Hash, c.HashAlgo); err == nil && digest != "" {
+			seen[digest] = true
+		}
+		seen[sha256Hex(collapseSpace(c.Content))] = true
+	}
+	for _, c := range scraped {
+		digest := sha256Hex(collapseSpace(c.Content))
+		if seen[digest] {
+			continue
+		}
+		seen[digest] = true
+		c.Hash = "sha256:" + sha256Hex(c.Content)
+		content = append(content, c)
+	}
+	return content
+}
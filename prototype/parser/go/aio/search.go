@@ -0,0 +1,218 @@
+package aio
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetLength is the approximate size in bytes of a search result
+// snippet, not counting ellipses
+const snippetLength = 160
+
+// SearchResult is one matched chunk condensed for a results page
+type SearchResult struct {
+	ChunkID string  `json:"chunk_id"`
+	Title   string  `json:"title,omitempty"`
+	Section string  `json:"section,omitempty"`
+	Score   float64 `json:"score"`
+
+	// Snippet is an excerpt of the chunk around its first match
+	Snippet string `json:"snippet"`
+
+	// Highlights are the byte ranges of Snippet matched by query terms,
+	// in order and non-overlapping
+	Highlights []Highlight `json:"highlights,omitempty"`
+}
+
+// Highlight is a half-open byte range [Start, End) of a snippet
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Marked returns the snippet with each highlight wrapped in open and
+// close, e.g. "<mark>" and "</mark>" or "**" and "**"
+func (r SearchResult) Marked(open, close string) string {
+	var b strings.Builder
+	last := 0
+	for _, h := range r.Highlights {
+		b.WriteString(r.Snippet[last:h.Start])
+		b.WriteString(open)
+		b.WriteString(r.Snippet[h.Start:h.End])
+		b.WriteString(close)
+		last = h.End
+	}
+	b.WriteString(r.Snippet[last:])
+	return b.String()
+}
+
+// SearchResults scores the document's chunks against query with the
+// default field weights and returns the matches, best first, each with a
+// highlighted snippet
+func (a *AIOFile) SearchResults(query string) []SearchResult {
+	terms := parseTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	entries, keywords := indexByID(a.Index), keywordsByID(a.Index)
+	var matched []Chunk
+	for i, chunk := range a.Content {
+		chunk.pos = i
+		score, _ := scoreChunk(chunk, entries[chunk.ID], keywords[chunk.ID], terms, DefaultFieldWeights)
+		if score > 0 {
+			chunk.Score = score
+			matched = append(matched, chunk)
+		}
+	}
+	rankChunks(matched, nil)
+
+	results := make([]SearchResult, 0, len(matched))
+	for _, chunk := range matched {
+		r := SearchResult{ChunkID: chunk.ID, Section: chunk.Section, Score: chunk.Score}
+		if entry := entries[chunk.ID]; entry != nil {
+			r.Title = entry.Title
+		}
+		content := strings.TrimSpace(chunk.Content)
+		r.Snippet, r.Highlights = snippet(content, findTerms(content, terms))
+		results = append(results, r)
+	}
+	return results
+}
+
+// findTerms returns the merged byte ranges of text matched by any term.
+// Matching is case-insensitive; text whose lower-cased form changes
+// length yields no ranges, as offsets would not line up.
+func findTerms(text string, terms []queryTerm) []Highlight {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return nil
+	}
+	var found []Highlight
+	for _, t := range terms {
+		if !t.glob {
+			if t.text == "" {
+				continue
+			}
+			for from := 0; ; {
+				i := strings.Index(lower[from:], t.text)
+				if i < 0 {
+					break
+				}
+				found = append(found, Highlight{from + i, from + i + len(t.text)})
+				from += i + len(t.text)
+			}
+			continue
+		}
+		for start := 0; start < len(lower); {
+			r, size := utf8.DecodeRuneInString(lower[start:])
+			if isWordSeparator(r) {
+				start += size
+				continue
+			}
+			end := start
+			for end < len(lower) {
+				r, size := utf8.DecodeRuneInString(lower[end:])
+				if isWordSeparator(r) {
+					break
+				}
+				end += size
+			}
+			if globMatch(t.text, lower[start:end]) {
+				found = append(found, Highlight{start, end})
+			}
+			start = end
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	var merged []Highlight
+	for _, h := range found {
+		if n := len(merged); n > 0 && h.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, h.End)
+			continue
+		}
+		merged = append(merged, h)
+	}
+	return merged
+}
+
+// snippet cuts about snippetLength bytes of text around the first
+// highlight, at word boundaries, and shifts the highlights that fall
+// inside it
+func snippet(text string, highlights []Highlight) (string, []Highlight) {
+	if len(text) <= snippetLength {
+		return cutSnippet(text, 0, len(text), highlights)
+	}
+	start := 0
+	if len(highlights) > 0 {
+		start = max(highlights[0].Start-snippetLength/4, 0)
+	}
+	end := min(start+snippetLength, len(text))
+	start = max(min(start, end-snippetLength), 0)
+	start = wordStart(text, runeStart(text, start))
+	end = wordEnd(text, runeStart(text, end))
+	return cutSnippet(text, start, end, highlights)
+}
+
+// cutSnippet slices text to [start, end), adding ellipses where
+// it was cut, and rebases the highlights within it
+func cutSnippet(text string, start, end int, highlights []Highlight) (string, []Highlight) {
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(text) {
+		suffix = "…"
+	}
+	var shifted []Highlight
+	for _, h := range highlights {
+		if h.Start < start || h.End > end {
+			continue
+		}
+		shifted = append(shifted, Highlight{h.Start - start + len(prefix), h.End - start + len(prefix)})
+	}
+	return prefix + text[start:end] + suffix, shifted
+}
+
+// wordStart moves i forward to the start of the next word unless it is
+// already at one
+func wordStart(text string, i int) int {
+	if i == 0 {
+		return 0
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	if unicode.IsSpace(r) {
+		return i
+	}
+	if j := strings.IndexFunc(text[i:], unicode.IsSpace); j >= 0 {
+		_, size := utf8.DecodeRuneInString(text[i+j:])
+		return i + j + size
+	}
+	return i
+}
+
+// wordEnd moves i back to the end of the previous word unless it already
+// ends one
+func wordEnd(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	if unicode.IsSpace(r) {
+		return i
+	}
+	if j := strings.LastIndexFunc(text[:i], unicode.IsSpace); j > 0 {
+		return j
+	}
+	return i
+}
+
+// runeStart moves i back to the first byte of the rune containing it
+func runeStart(text string, i int) int {
+	for i > 0 && i < len(text) && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,319 @@
+package aio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Sanitizer inspects the content of a selected chunk before it reaches
+// the envelope (Options.Sanitizer). It returns the chunk as it should be
+// used, the actions it took, and false to leave the chunk out entirely.
+// Sanitize is called from concurrent parses.
+type Sanitizer interface {
+	Sanitize(c Chunk) (Chunk, []SanitizeAction, bool)
+}
+
+// SanitizerFunc adapts a function to Sanitizer
+type SanitizerFunc func(c Chunk) (Chunk, []SanitizeAction, bool)
+
+func (f SanitizerFunc) Sanitize(c Chunk) (Chunk, []SanitizeAction, bool) {
+	return f(c)
+}
+
+// SanitizeAction records one change a Sanitizer made to untrusted content
+type SanitizeAction struct {
+	ChunkID string `json:"chunk_id"`
+
+	// Rule names what was found, e.g. "script", "html", "hidden-text" or
+	// "prompt-injection", and Action what was done about it: "stripped"
+	// or "rejected", for a chunk left out
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+
+	// Excerpt is the start of what matched, for review
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+// Sanitize actions
+const (
+	ActionStripped = "stripped"
+	ActionRejected = "rejected"
+)
+
+// DefaultInjectionPatterns match phrasing that addresses a language model
+// rather than the reader: instructions to drop its earlier instructions,
+// claims to be the system prompt and chat template control tokens
+var DefaultInjectionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,40}\b(previous|prior|above|earlier|all|any|your)\b[^.\n]{0,20}\b(instructions?|prompts?|rules|directions)\b`),
+	regexp.MustCompile(`(?i)\byou are now (in )?(a |an |the )?[\w-]+ (mode|assistant|persona|ai|model)\b`),
+	regexp.MustCompile(`(?i)\b(new|updated|real)\s+(system\s+)?instructions\s*:`),
+	regexp.MustCompile(`(?i)^[\s#]*(system|assistant|developer)\s+(prompt|message)\s*:`),
+	regexp.MustCompile(`<\|[a-z_]+\|>|\[/?INST\]|<</?SYS>>`),
+}
+
+var (
+	unsafeElement = regexp.MustCompile(`(?is)<(script|style|iframe|object|embed|template|noscript)\b[^>]*>.*?</\s*(script|style|iframe|object|embed|template|noscript)\s*>|<(script|style|iframe|object|embed|meta|link|base)\b[^>]*>`)
+	unsafeURL     = regexp.MustCompile(`(?i)\b(javascript|vbscript|data:text/html)\s*:[^\s)"'>]*`)
+	htmlTag       = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9-]*(\s[^<>]*)?/?>|<!--.*?-->`)
+	hiddenText    = regexp.MustCompile(`[\x{200b}-\x{200f}\x{202a}-\x{202e}\x{2060}-\x{2064}\x{2066}-\x{2069}\x{feff}\x{e0000}-\x{e007f}]+`)
+)
+
+// ContentSanitizer is a Sanitizer for content from sites the caller does
+// not trust. It strips script and other active elements, javascript:
+// links, invisible characters that can hide text from a reader, and HTML
+// tags unless AllowHTML; and lines matching InjectionPatterns. With
+// Reject, a chunk with any of these is left out instead.
+type ContentSanitizer struct {
+	// Reject leaves out chunks with unsafe content rather than stripping it
+	Reject bool
+
+	// AllowHTML keeps HTML tags other than active elements
+	AllowHTML bool
+
+	// InjectionPatterns are matched against each line of content; nil uses
+	// DefaultInjectionPatterns, and an empty slice matches nothing
+	InjectionPatterns []*regexp.Regexp
+}
+
+func (s *ContentSanitizer) Sanitize(c Chunk) (Chunk, []SanitizeAction, bool) {
+	var actions []SanitizeAction
+	strip := func(rule string, re *regexp.Regexp, replacement string) {
+		if m := re.FindString(c.Content); m != "" {
+			actions = append(actions, SanitizeAction{ChunkID: c.ID, Rule: rule, Action: ActionStripped, Excerpt: excerpt(m)})
+			c.Content = re.ReplaceAllString(c.Content, replacement)
+		}
+	}
+	strip("script", unsafeElement, "")
+	strip("script", unsafeURL, "")
+	strip("hidden-text", hiddenText, "")
+	if !s.AllowHTML {
+		strip("html", htmlTag, "")
+	}
+
+	patterns := s.InjectionPatterns
+	if patterns == nil {
+		patterns = DefaultInjectionPatterns
+	}
+	if len(patterns) > 0 {
+		lines := strings.Split(c.Content, "\n")
+		kept := lines[:0]
+		for _, line := range lines {
+			if m := matchAny(patterns, line); m != "" {
+				actions = append(actions, SanitizeAction{ChunkID: c.ID, Rule: "prompt-injection", Action: ActionStripped, Excerpt: excerpt(m)})
+				continue
+			}
+			kept = append(kept, line)
+		}
+		c.Content = strings.Join(kept, "\n")
+	}
+
+	if s.Reject && len(actions) > 0 {
+		for i := range actions {
+			actions[i].Action = ActionRejected
+		}
+		return c, actions, false
+	}
+	return c, actions, true
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) string {
+	for _, re := range patterns {
+		if m := re.FindString(s); m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// excerpt shortens matched content for a SanitizeAction
+func excerpt(s string) string {
+	const max = 60
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+// sanitizeChunks passes chunks through s, leaving out the ones it rejects
+func sanitizeChunks(chunks []Chunk, s Sanitizer) ([]Chunk, []SanitizeAction) {
+	if s == nil {
+		return chunks, nil
+	}
+	var all []SanitizeAction
+	kept := chunks[:0]
+	for _, c := range chunks {
+		c, actions, keep := s.Sanitize(c)
+		all = append(all, actions...)
+		if keep {
+			kept = append(kept, c)
+		}
+	}
+	return kept, all
+}
+
+// ErrPrivateAddress is returned for a request to a loopback, private or
+// link-local address that Options.AllowPrivateNetworks does not permit
+var ErrPrivateAddress = errors.New("aio: address is on a private network")
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPrivateAddress reports whether ip is off the public internet
+func isPrivateAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() || cgnat.Contains(ip)
+}
+
+// netGuard keeps a session's requests off private networks, protecting
+// callers that parse URLs on a user's behalf from being pointed at their
+// own internal services. Only the hosts it is told to trust may resolve
+// to private addresses: that of the URL passed to Parse under
+// Options.TrustParseHost, and a configured proxy's.
+type netGuard struct {
+	allowAll bool
+
+	mu      sync.Mutex
+	trusted map[string]bool
+}
+
+func newNetGuard(opts Options) *netGuard {
+	return &netGuard{allowAll: opts.AllowPrivateNetworks}
+}
+
+// trust allows requests to the host of rawURL whatever it resolves to
+func (g *netGuard) trust(rawURL string) {
+	host := hostOf(rawURL)
+	if host == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.trusted == nil {
+		g.trusted = map[string]bool{}
+	}
+	g.trusted[host] = true
+}
+
+func (g *netGuard) trusts(host string) bool {
+	if g.allowAll {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.trusted[strings.ToLower(host)]
+}
+
+// hostOf is the lower-cased host name of a URL, without its port
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// resolve looks up host and fails with ErrPrivateAddress when it is
+// untrusted and any of its addresses is private
+func (g *netGuard) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{ip}
+	} else {
+		ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		addrs = ips
+	}
+	if g.trusts(host) {
+		return addrs, nil
+	}
+	for _, ip := range addrs {
+		if isPrivateAddress(ip) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, ip.Unmap())
+		}
+	}
+	return addrs, nil
+}
+
+// check is the guard for clients the session did not build, whose
+// transport it cannot reach: the request's host is resolved and checked
+// before the request and each redirect is sent. The client dials on its
+// own lookup, so a name that resolves to a private address only by then
+// gets through; a nil Parser.Client closes that gap.
+func (g *netGuard) check(ctx context.Context, req *http.Request) error {
+	if g.trusts(req.URL.Hostname()) {
+		return nil
+	}
+	_, err := g.resolve(ctx, req.URL.Hostname())
+	return err
+}
+
+// dialContext connects to one of the checked addresses of addr, so a name
+// cannot resolve to a public address when checked and a private one when
+// dialed
+func (g *netGuard) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := g.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// guardedTransport is http.DefaultTransport dialing through g. A request
+// sent through a proxy dials the proxy, never the target, so the target
+// is checked before the request is sent and the proxy the environment
+// names is trusted.
+func guardedTransport(g *netGuard) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = g.dialContext
+	t.Proxy = guardProxy(g, t.Proxy)
+	return t
+}
+
+// guardProxy wraps proxy to check the target of each request it sends
+// through a proxy
+func guardProxy(g *netGuard, proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			return u, err
+		}
+		if err := g.check(req.Context(), req); err != nil {
+			return nil, err
+		}
+		g.trust(u.String())
+		return u, nil
+	}
+}
@@ -0,0 +1,123 @@
+package aio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPrivateNetworkGuard(t *testing.T) {
+	// The next page is named as localhost, a host other than the
+	// 127.0.0.1 of the URL parsed
+	other := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "content": [{"id": "two", "content": "Second page."}]}`
+	})
+	next := strings.Replace(other.URL, "127.0.0.1", "localhost", 1) + "/ai-content.aio"
+	origin := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "next": "` + next + `", "content": [{"id": "one", "content": "First page."}]}`
+	})
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "parse host untrusted", wantErr: true},
+		{name: "next page on another private host", opts: Options{TrustParseHost: true}, wantErr: true},
+		{name: "private networks allowed", opts: Options{AllowPrivateNetworks: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.IgnoreRobots = true
+			opts.FollowNext = true
+			env, err := ParseWithOptions(origin.URL+"/", "", opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrPrivateAddress) {
+					t.Fatalf("err = %v, want ErrPrivateAddress", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(env.Items) != 2 {
+				t.Errorf("got %d chunks, want both pages", len(env.Items))
+			}
+		})
+	}
+}
+
+func TestTrustParseHost(t *testing.T) {
+	srv := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "content": [{"id": "one", "content": "Local page."}]}`
+	})
+	opts := Options{TrustParseHost: true, IgnoreRobots: true}
+	if _, err := ParseWithOptions(srv.URL+"/", "", opts); err != nil {
+		t.Fatalf("trusted parse host: %v", err)
+	}
+
+	// A client of the handler cannot point it at the server's network
+	h := NewHandler(&Parser{Options: opts})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/parse?url="+url.QueryEscape(srv.URL+"/"), nil))
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "private") {
+		t.Errorf("handler got %d %q, want the private address refused", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGuardProxy(t *testing.T) {
+	g := newNetGuard(Options{})
+	proxy := guardProxy(g, http.ProxyURL(&url.URL{Scheme: "http", Host: "localhost:3128"}))
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://127.0.0.1:8080/", nil)
+	if _, err := proxy(req); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("private target through a proxy: err = %v, want ErrPrivateAddress", err)
+	}
+
+	g.trust("http://127.0.0.1:8080/")
+	u, err := proxy(req)
+	if err != nil || u.Host != "localhost:3128" {
+		t.Fatalf("trusted target: %v, %v", u, err)
+	}
+	if !g.trusts("localhost") {
+		t.Error("the proxy was not trusted for the dial")
+	}
+}
+
+func TestCallerClientGuard(t *testing.T) {
+	// Both servers listen on 127.0.0.1; only the one parsed, named as
+	// localhost, is trusted
+	private := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "content": [{"id": "one", "content": "Private page."}]}`
+	})
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, private.URL+"/ai-content.aio", http.StatusFound)
+	}))
+	t.Cleanup(redirect.Close)
+	p := &Parser{Client: &http.Client{}, Options: Options{TrustParseHost: true, IgnoreRobots: true}}
+	_, err := p.Parse(strings.Replace(redirect.URL, "127.0.0.1", "localhost", 1)+"/", "")
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("redirect to 127.0.0.1: err = %v, want ErrPrivateAddress", err)
+	}
+	if _, ok := private.auth("/ai-content.aio"); ok {
+		t.Error("the redirect was followed to the private address")
+	}
+
+	// Mirrors are trusted with the parse host, so neither is here, and
+	// probing them for latency must not reach either
+	mirror := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "content": [{"id": "one", "content": "Mirrored page."}]}`
+	})
+	p.Options = Options{IgnoreRobots: true, Mirrors: []string{mirror.URL + "/"}, MirrorsByLatency: true}
+	if _, err := p.Parse(private.URL+"/", ""); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("private mirrors: err = %v, want ErrPrivateAddress", err)
+	}
+	for _, r := range []*authRecorder{private, mirror} {
+		if _, ok := r.auth("/"); ok {
+			t.Errorf("%s was probed", r.URL)
+		}
+	}
+}
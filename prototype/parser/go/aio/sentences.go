@@ -0,0 +1,107 @@
+package aio
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// topSentences splits chunks into sentences and returns the n that match
+// the most query terms (weighted by boost), each as a Chunk carrying its source chunk's ID.
+// Sentences matching no term are left out, unless there are no terms, when
+// the first n are taken. Sentences are lower-cased by the rules of the
+// chunk's language, or else lang. The winners are returned in reading
+// order: by chunk rank, then by their position within the chunk.
+func topSentences(chunks []Chunk, terms []queryTerm, n int, lang string) []Chunk {
+	type candidate struct {
+		unit  Chunk
+		order int
+	}
+	var candidates []candidate
+	for _, chunk := range chunks {
+		lower := lowerFor(firstNonEmpty(chunk.lang, lang))
+		for _, sentence := range splitSentences(chunk.Content) {
+			text := lower(sentence)
+			score := 0.0
+			for _, term := range terms {
+				if term.matches(text) {
+					score += term.boost
+				}
+			}
+			if score == 0 && len(terms) > 0 {
+				continue
+			}
+			candidates = append(candidates, candidate{
+				unit: Chunk{
+					ID:       chunk.ID,
+					Content:  sentence,
+					Section:  chunk.Section,
+					Citation: chunk.Citation,
+					Score:    score,
+					pos:      chunk.pos,
+				},
+				order: len(candidates),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].unit.Score > candidates[j].unit.Score
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].order < candidates[j].order
+	})
+
+	units := make([]Chunk, len(candidates))
+	for i, c := range candidates {
+		units[i] = c.unit
+	}
+	return units
+}
+
+// splitSentences breaks text into sentences at line breaks and at . ! or ?
+// followed by whitespace. Lines without any letters or digits, such as
+// Markdown rules and table separators, are dropped.
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, line := range strings.Split(text, "\n") {
+		start := 0
+		runes := []rune(line)
+		for i, r := range runes {
+			if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+				sentences = appendSentence(sentences, string(runes[start:i+1]))
+				start = i + 1
+			}
+		}
+		sentences = appendSentence(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+func appendSentence(sentences []string, s string) []string {
+	s = strings.TrimSpace(s)
+	if strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return sentences
+	}
+	return append(sentences, s)
+}
+
+// sourceChunks returns the chunks that contributed at least one unit,
+// in their original order, each costing the tokens of its units
+func sourceChunks(chunks []Chunk, units []Chunk) []Chunk {
+	tokens := make(map[string]int, len(units))
+	for _, u := range units {
+		tokens[u.ID] += u.Tokens
+	}
+	var out []Chunk
+	for _, chunk := range chunks {
+		if n, ok := tokens[chunk.ID]; ok {
+			chunk.Tokens = n
+			out = append(out, chunk)
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package aio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopSentences(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []Chunk
+		query  string
+		lang   string
+		n      int
+		want   []string
+	}{
+		{
+			name:   "best first in reading order",
+			chunks: []Chunk{{ID: "a", Content: "Plans start free. Pricing is per seat. Pricing and plans are monthly."}},
+			query:  "pricing plans",
+			n:      2,
+			want:   []string{"Plans start free.", "Pricing and plans are monthly."},
+		},
+		{
+			name:   "zero-score sentences left out",
+			chunks: []Chunk{{ID: "a", Content: "Pricing is per seat. We were founded in 2010. Our office is in Berlin."}},
+			query:  "pricing",
+			n:      3,
+			want:   []string{"Pricing is per seat."},
+		},
+		{
+			name:   "no query takes the first",
+			chunks: []Chunk{{ID: "a", Content: "One. Two. Three."}},
+			n:      2,
+			want:   []string{"One.", "Two."},
+		},
+		{
+			name:   "turkish lower-casing",
+			chunks: []Chunk{{ID: "a", Content: "IŞIK fiyatı düşük. Başka bir cümle."}},
+			query:  "ışık",
+			lang:   "tr",
+			n:      2,
+			want:   []string{"IŞIK fiyatı düşük."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms := queryTerms(lowerFor(tt.lang)(tt.query), Options{})
+			var got []string
+			for _, u := range topSentences(tt.chunks, terms, tt.n, tt.lang) {
+				got = append(got, u.Content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
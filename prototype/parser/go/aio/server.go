@@ -0,0 +1,226 @@
+package aio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds how long Serve waits for in-flight requests to
+// finish once its context is cancelled
+const shutdownTimeout = 30 * time.Second
+
+// NewHandler serves parses over HTTP:
+//
+//	GET /parse?url=...&query=...         the content envelope as JSON
+//	GET /parse/stream?url=...&query=...  the finished narrative as
+//	                                     Server-Sent Events
+//	GET /capabilities                    the parser's Capabilities as JSON
+//	GET /healthz                         200 while the process is serving
+//	GET /readyz                          200 when the proxy settings and
+//	                                     the cache are usable, 503
+//	                                     otherwise
+//	GET /metrics                         the Parser's Metrics, when they are
+//	                                     an http.Handler such as
+//	                                     PrometheusMetrics
+//
+// Once the parse is done, the stream sends one "chunk" event per narrative
+// block, with a StreamEvent as its data, then a "done" event carrying the
+// envelope ID and token count, or an "error" event.
+//
+// The URLs come from clients, so Options.TrustParseHost is ignored.
+func NewHandler(p *Parser) http.Handler {
+	p = untrusted(p)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/parse", func(w http.ResponseWriter, r *http.Request) {
+		url, query, ok := parseParams(w, r)
+		if !ok {
+			return
+		}
+		env, err := p.ParseContext(r.Context(), url, query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(env)
+	})
+	mux.HandleFunc("/parse/stream", func(w http.ResponseWriter, r *http.Request) {
+		url, query, ok := parseParams(w, r)
+		if !ok {
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		env, err := p.ParseStream(r.Context(), url, query, func(ev StreamEvent) error {
+			if err := writeEvent(w, "chunk", ev); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		})
+		if err != nil {
+			writeEvent(w, "error", map[string]string{"error": err.Error()})
+		} else {
+			writeEvent(w, "done", map[string]any{"id": env.ID, "tokens": env.Tokens})
+		}
+		flusher.Flush()
+	})
+	mux.HandleFunc("/capabilities", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Capabilities())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		checks := p.readiness()
+		status := http.StatusOK
+		for _, result := range checks {
+			if result != "ok" {
+				status = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(checks)
+	})
+	if h, ok := p.Metrics.(http.Handler); ok {
+		mux.Handle("/metrics", h)
+	}
+	return mux
+}
+
+// readiness runs the parser's dependency checks, mapping each to "ok" or
+// the error it failed with
+func (p *Parser) readiness() map[string]string {
+	checks := map[string]string{}
+	report := func(name string, err error) {
+		checks[name] = "ok"
+		if err != nil {
+			checks[name] = err.Error()
+		}
+	}
+	report("proxy", p.checkProxy())
+	if cache := cacheFor(p.Options); cache != nil {
+		report("cache", checkCache(cache))
+	}
+	return checks
+}
+
+// checkProxy asks the transport parses send through for the proxy of a
+// request, which fails when, for instance, HTTPS_PROXY is malformed
+func (p *Parser) checkProxy() error {
+	rt := http.DefaultTransport
+	if p.Client != nil && p.Client.Transport != nil {
+		rt = p.Client.Transport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok || t.Proxy == nil {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://readiness.invalid/", nil)
+	if err != nil {
+		return err
+	}
+	_, err = t.Proxy(req)
+	return err
+}
+
+// readinessKey is the cache key checkCache writes, which no normalized
+// document URL takes
+const readinessKey = "aio:readiness"
+
+// checkCache puts an entry into cache and reads it back
+func checkCache(cache Cache) error {
+	if fc, ok := cache.(*FileCache); ok {
+		if err := fc.check(); err != nil {
+			return err
+		}
+	}
+	body := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	cache.Put(&CacheEntry{URL: readinessKey, Body: body})
+	if e := cache.Get(readinessKey); e == nil || !bytes.Equal(e.Body, body) {
+		return errors.New("an entry put into the cache did not come back")
+	}
+	return nil
+}
+
+// Serve runs NewHandler(p) on addr until ctx is cancelled, then stops
+// accepting connections and waits up to shutdownTimeout for in-flight
+// requests to drain. It returns nil after a clean shutdown.
+func Serve(ctx context.Context, addr string, p *Parser) error {
+	return ServeHandler(ctx, addr, NewHandler(p))
+}
+
+// ServeHandler is Serve for any handler, such as a NewDocumentHandler
+func ServeHandler(ctx context.Context, addr string, h http.Handler) error {
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     h,
+		BaseContext: func(net.Listener) context.Context { return context.WithoutCancel(ctx) },
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// untrusted returns a copy of p for parsing URLs named by clients, who
+// must not be able to reach the parser's private network through them
+func untrusted(p *Parser) *Parser {
+	c := *p
+	c.Options.TrustParseHost = false
+	return &c
+}
+
+// parseParams reads the url and query parameters of a parse request,
+// answering the request itself when they are unusable
+func parseParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return "", "", false
+	}
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		http.Error(w, "missing url parameter", http.StatusBadRequest)
+		return "", "", false
+	}
+	return url, r.URL.Query().Get("query"), true
+}
+
+// writeEvent writes one Server-Sent Event with data encoded as JSON,
+// which never spans lines
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
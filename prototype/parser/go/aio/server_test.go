@@ -0,0 +1,52 @@
+package aio
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// forgetfulCache stores nothing
+type forgetfulCache struct{}
+
+func (forgetfulCache) Get(string) *CacheEntry { return nil }
+func (forgetfulCache) Put(*CacheEntry)        {}
+
+func TestReadiness(t *testing.T) {
+	badProxy := &http.Client{Transport: &http.Transport{Proxy: func(*http.Request) (*url.URL, error) {
+		return nil, errors.New("invalid proxy address")
+	}}}
+	tests := []struct {
+		name   string
+		parser *Parser
+		status int
+		failed string
+	}{
+		{name: "defaults", parser: &Parser{}, status: http.StatusOK},
+		{name: "memory cache", parser: &Parser{Options: Options{Cache: NewMemoryCache(0)}}, status: http.StatusOK},
+		{name: "file cache", parser: &Parser{Options: Options{CacheDir: t.TempDir()}}, status: http.StatusOK},
+		{name: "cache loses entries", parser: &Parser{Options: Options{Cache: forgetfulCache{}}}, status: http.StatusServiceUnavailable, failed: "cache"},
+		{name: "proxy fails", parser: &Parser{Client: badProxy}, status: http.StatusServiceUnavailable, failed: "proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewHandler(tt.parser).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			var checks map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &checks); err != nil {
+				t.Fatal(err)
+			}
+			for name, result := range checks {
+				if (name == tt.failed) == (result == "ok") {
+					t.Errorf("check %s reported %q", name, result)
+				}
+			}
+		})
+	}
+}
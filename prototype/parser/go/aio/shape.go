@@ -0,0 +1,159 @@
+package aio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformed matches, through errors.Is, the ShapeError of a document
+// whose JSON does not have the structure of an AIO file, and the error of
+// one that is not valid JSON at all
+var ErrMalformed = errors.New("aio: malformed document")
+
+// ShapeError names a field of a document with the wrong JSON type
+type ShapeError struct {
+	Field string // e.g. "content" or "content[2]"
+	Want  string // e.g. "an array"
+	Got   string // the JSON type found, e.g. "an object" or "null"
+}
+
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf("%v: %s must be %s, got %s", ErrMalformed, e.Field, e.Want, e.Got)
+}
+
+func (e *ShapeError) Is(target error) bool { return target == ErrMalformed }
+
+// checkShape verifies the top-level structure of a document before it is
+// decoded, since encoding/json reports a wrongly typed field with Go type
+// names and no hint of where the document went wrong. The document must
+// be an object whose content is an array of objects; index may be absent
+// or null but otherwise must be an array. An index document listing child
+// documents may leave content out.
+func checkShape(data []byte) error {
+	if got := jsonType(data); got != "an object" {
+		return &ShapeError{Field: "the document", Want: "an object", Got: got}
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return shapeError(err)
+	}
+
+	for _, field := range []string{"index", "documents"} {
+		if v, ok := top[field]; ok {
+			if got := jsonType(v); got != "an array" && got != "null" {
+				return &ShapeError{Field: field, Want: "an array", Got: got}
+			}
+		}
+	}
+	content, ok := top["content"]
+	if !ok && jsonType(top["documents"]) == "an array" {
+		return nil
+	}
+	if !ok {
+		return &ShapeError{Field: "content", Want: "an array", Got: "nothing (the field is missing)"}
+	}
+	if got := jsonType(content); got != "an array" {
+		return &ShapeError{Field: "content", Want: "an array", Got: got}
+	}
+	var chunks []json.RawMessage
+	if err := json.Unmarshal(content, &chunks); err != nil {
+		return err
+	}
+	for i, c := range chunks {
+		if got := jsonType(c); got != "an object" {
+			return &ShapeError{Field: fmt.Sprintf("content[%d]", i), Want: "an object", Got: got}
+		}
+	}
+	return nil
+}
+
+// shapeError rewrites a decoding type error in the document's terms, and
+// marks a syntax error as ErrMalformed
+func shapeError(err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	var te *json.UnmarshalTypeError
+	if !errors.As(err, &te) || te.Field == "" {
+		return err
+	}
+	return &ShapeError{Field: fieldPath(te.Field), Want: jsonKind(te.Type), Got: articled(te.Value)}
+}
+
+// fieldPath writes the array indices of a decoder field path, such as
+// "content.2.id", in subscript form: "content[2].id"
+func fieldPath(field string) string {
+	parts := strings.Split(field, ".")
+	var b strings.Builder
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// jsonType names the JSON type of a raw value, with an article, as it
+// reads in an error message
+func jsonType(raw []byte) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "nothing"
+	}
+	switch raw[0] {
+	case '{':
+		return "an object"
+	case '[':
+		return "an array"
+	case '"':
+		return "a string"
+	case 't', 'f':
+		return "a boolean"
+	case 'n':
+		return "null"
+	default:
+		return "a number"
+	}
+}
+
+// jsonKind names the JSON type a Go type decodes from
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	default:
+		return "a number"
+	}
+}
+
+// articled turns the bare JSON type names of json.UnmarshalTypeError
+// ("array", "number 3") into the form jsonType uses
+func articled(value string) string {
+	switch {
+	case value == "array", value == "object":
+		return "an " + value
+	case value == "null":
+		return value
+	default:
+		return "a " + value
+	}
+}
@@ -0,0 +1,42 @@
+package aio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestShapeError(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want ShapeError
+	}{
+		{"document is an array", `[]`, ShapeError{Field: "the document", Want: "an object", Got: "an array"}},
+		{"content missing", `{"aio_version": "2.1"}`, ShapeError{Field: "content", Want: "an array", Got: "nothing (the field is missing)"}},
+		{"content is an object", `{"aio_version": "2.1", "content": {"id": "a"}}`, ShapeError{Field: "content", Want: "an array", Got: "an object"}},
+		{"content is null", `{"aio_version": "2.1", "content": null}`, ShapeError{Field: "content", Want: "an array", Got: "null"}},
+		{"chunk is a string", `{"aio_version": "2.1", "content": [{"id": "a", "content": "A"}, "b"]}`, ShapeError{Field: "content[1]", Want: "an object", Got: "a string"}},
+		{"index is a string", `{"aio_version": "2.1", "index": "x", "content": []}`, ShapeError{Field: "index", Want: "an array", Got: "a string"}},
+		{"chunk id is a number", `{"aio_version": "2.1", "content": [{"id": 7, "content": "A"}]}`, ShapeError{Field: "content[0].id", Want: "a string", Got: "a number"}},
+		{"chunk content is an array", `{"aio_version": "2.1", "content": [{"id": "a", "content": ["A"]}]}`, ShapeError{Field: "content[0].content", Want: "a string", Got: "an array"}},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/stream=%v", tt.name, stream), func(t *testing.T) {
+				_, err := parseAIO(strings.NewReader(tt.doc), "", "", Options{StreamDecode: stream})
+				var se *ShapeError
+				if !errors.As(err, &se) {
+					t.Fatalf("err = %v, want a ShapeError", err)
+				}
+				if *se != tt.want {
+					t.Errorf("got %+v, want %+v", *se, tt.want)
+				}
+				if !errors.Is(err, ErrMalformed) {
+					t.Error("a ShapeError is not ErrMalformed")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package aio
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when a document's signature does not
+// verify, or when a signature is required and cannot be checked.
+var ErrInvalidSignature = errors.New("aio: invalid signature")
+
+// Signature is the signature block of a .aio document (spec section 6)
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id,omitempty"`
+	Value     string `json:"value"`
+	Covers    string `json:"covers,omitempty"`
+}
+
+// checkSignature verifies the signature block of the raw document when the
+// caller supplied a key, reporting whether a verification took place
+func checkSignature(data []byte, sig *Signature, opts Options) (bool, error) {
+	if sig == nil || sig.Value == "" {
+		if opts.RequireSignature {
+			return false, fmt.Errorf("%w: document is not signed", ErrInvalidSignature)
+		}
+		return false, nil
+	}
+	if opts.PublicKey == nil {
+		if opts.RequireSignature {
+			return false, fmt.Errorf("%w: no public key to verify with", ErrInvalidSignature)
+		}
+		return false, nil
+	}
+	if !strings.EqualFold(sig.Algorithm, "Ed25519") {
+		return false, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, sig.Algorithm)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return false, fmt.Errorf("%w: malformed value: %v", ErrInvalidSignature, err)
+	}
+	payload, err := signingPayload(data)
+	if err != nil {
+		return false, err
+	}
+	if !ed25519.Verify(opts.PublicKey, payload, value) {
+		return false, ErrInvalidSignature
+	}
+	return true, nil
+}
+
+// signingPayload rebuilds the signed bytes from the raw document:
+// canonical index JSON, a newline, then canonical content JSON
+func signingPayload(data []byte) ([]byte, error) {
+	var raw struct {
+		Index   json.RawMessage `json:"index"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	index, err := canonicalJSON(raw.Index)
+	if err != nil {
+		return nil, err
+	}
+	content, err := canonicalJSON(raw.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := append(index, '\n')
+	return append(payload, content...), nil
+}
+
+// canonicalJSON re-encodes raw JSON with sorted keys and no whitespace.
+// Numbers are kept verbatim so re-encoding cannot change their spelling.
+func canonicalJSON(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 {
+		raw = json.RawMessage("null")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,294 @@
+package aio
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SourceStrategy names one way of obtaining a site's content. Parse tries
+// the strategies of Options.FallbackChain in order until one yields
+// content.
+type SourceStrategy string
+
+const (
+	// StrategyDirectURL fetches ai-content.aio directly under the Parse
+	// URL, e.g. https://example.com/docs/ai-content.aio for
+	// https://example.com/docs, /docs/ or /docs/index.html. The page's
+	// query string is kept and its fragment dropped.
+	StrategyDirectURL SourceStrategy = "direct-url"
+
+	// StrategyWellKnown fetches /.well-known/ai-content.aio at the site's
+	// origin
+	StrategyWellKnown SourceStrategy = "well-known"
+
+	// StrategyLinkHeader fetches the page at the Parse URL and follows its
+	// `Link: <...>; rel="ai-content"` response header
+	StrategyLinkHeader SourceStrategy = "link-header"
+
+	// StrategyLinkElement fetches the page at the Parse URL and follows a
+	// <link rel="ai-content" href="..."> element in its HTML
+	StrategyLinkElement SourceStrategy = "link-element"
+
+	// StrategySitemap reads /sitemap.xml at the site's origin and fetches
+	// the first listed URL ending in .aio
+	StrategySitemap SourceStrategy = "sitemap"
+
+	// StrategyScrape extracts the readable text of the page at the Parse
+	// URL as chunks. It never fails over to anything else, so it belongs
+	// at the end of a chain.
+	StrategyScrape SourceStrategy = "scrape"
+)
+
+// DefaultFallbackChain is used when Options.FallbackChain is empty: every
+// published location of an AIO document, cheapest first, then scraping
+var DefaultFallbackChain = []SourceStrategy{
+	StrategyDirectURL,
+	StrategyWellKnown,
+	StrategyLinkHeader,
+	StrategyLinkElement,
+	StrategySitemap,
+	StrategyScrape,
+}
+
+// ErrNoSource is returned when no strategy of the fallback chain yielded
+// content; it wraps each strategy's error
+var ErrNoSource = errors.New("aio: no source yielded content")
+
+// located is the content one strategy produced
+type located struct {
+	strategy SourceStrategy
+	url      string   // the document URL, or the page URL when scraped
+	body     []byte   // the bytes fetched from url
+	doc      *AIOFile // already decoded content, set for StrategyScrape
+}
+
+// locator runs the fallback chain for one Parse URL, fetching the page
+// there at most once however many strategies need it
+type locator struct {
+	s       *session
+	pageURL string
+
+	pageFetched bool
+	page        []byte
+	pageHeader  http.Header
+	pageErr     error
+}
+
+// locate tries each strategy of chain in order. When Options.Scheme
+// allows several schemes, each strategy tries them all before the next
+// strategy runs, so a document published on either scheme is found ahead
+// of a scraped page.
+func locate(s *session, pageURL string, chain []SourceStrategy) (*located, error) {
+	if len(chain) == 0 {
+		chain = DefaultFallbackChain
+	}
+	variants := schemeVariants(pageURL, s.opts.Scheme)
+	locators := make([]*locator, len(variants))
+	for i, v := range variants {
+		locators[i] = &locator{s: s, pageURL: v}
+	}
+	var errs []error
+	for _, strategy := range chain {
+		for _, l := range locators {
+			loc, err := l.try(strategy)
+			if err == nil {
+				loc.strategy = strategy
+				return loc, nil
+			}
+			if ctxErr := s.ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			// Moving on to the next strategy would only hit the same
+			// server again while it is asking us to back off
+			if errors.Is(err, ErrRateLimited) {
+				return nil, err
+			}
+			s.run.logf("aio: %s for %s: %v", strategy, l.pageURL, err)
+			s.run.obs.fallback(FallbackEvent{URL: l.pageURL, Strategy: strategy, Err: err})
+			if len(locators) > 1 {
+				err = fmt.Errorf("%s: %w", l.pageURL, err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", strategy, err))
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrNoSource, errors.Join(errs...))
+}
+
+func (l *locator) try(strategy SourceStrategy) (*located, error) {
+	switch strategy {
+	case StrategyDirectURL:
+		doc, err := directURL(l.pageURL, l.s.opts.IndexDocuments)
+		if err != nil {
+			return nil, err
+		}
+		return l.fetchDocument(doc)
+	case StrategyWellKnown:
+		doc, err := resolveReference(l.pageURL, "/.well-known/ai-content.aio")
+		if err != nil {
+			return nil, err
+		}
+		return l.fetchDocument(doc)
+	case StrategyLinkHeader:
+		_, header, err := l.fetchPage()
+		if err != nil {
+			return nil, err
+		}
+		target := linkTarget(header.Values("Link"), "ai-content")
+		if target == "" {
+			return nil, errors.New(`no Link header with rel="ai-content"`)
+		}
+		doc, err := resolveReference(l.pageURL, target)
+		if err != nil {
+			return nil, err
+		}
+		return l.fetchDocument(doc)
+	case StrategyLinkElement:
+		page, _, err := l.fetchPage()
+		if err != nil {
+			return nil, err
+		}
+		target := linkElement(page, "ai-content")
+		if target == "" {
+			return nil, errors.New(`no <link rel="ai-content"> in the page`)
+		}
+		doc, err := resolveReference(l.pageURL, target)
+		if err != nil {
+			return nil, err
+		}
+		return l.fetchDocument(doc)
+	case StrategySitemap:
+		return l.fromSitemap()
+	case StrategyScrape:
+		if l.s.opts.RequireSignature {
+			return nil, fmt.Errorf("%w: scraped content cannot be signed", ErrInvalidSignature)
+		}
+		page, _, err := l.fetchPage()
+		if err != nil {
+			return nil, err
+		}
+		chunks, err := extract(l.s.opts, l.pageURL, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 0 {
+			return nil, errors.New("page has no readable text")
+		}
+		doc := &AIOFile{Content: chunks, Index: scrapedIndex(chunks)}
+		return &located{url: l.pageURL, body: page, doc: doc}, nil
+	default:
+		return nil, fmt.Errorf("unknown source strategy %q", strategy)
+	}
+}
+
+// DefaultIndexDocuments are the page names Options.IndexDocuments
+// defaults to
+var DefaultIndexDocuments = []string{"index.html", "index.htm"}
+
+// directURL names ai-content.aio in the directory of pageURL. A last path
+// segment found in index (DefaultIndexDocuments when nil) names that
+// directory's index page and is dropped; any other path is taken as the
+// directory itself, with or without a trailing slash.
+func directURL(pageURL string, index []string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	if index == nil {
+		index = DefaultIndexDocuments
+	}
+	u.Fragment, u.RawFragment = "", ""
+	dir := u.EscapedPath()
+	if i := strings.LastIndex(dir, "/"); i >= 0 {
+		for _, name := range index {
+			if strings.EqualFold(dir[i+1:], name) {
+				dir = dir[:i]
+				break
+			}
+		}
+	}
+	dir = strings.TrimSuffix(dir, "/") + "/ai-content.aio"
+	if u.Path, err = url.PathUnescape(dir); err != nil {
+		return "", err
+	}
+	u.RawPath = dir
+	return u.String(), nil
+}
+
+func (l *locator) fetchDocument(docURL string) (*located, error) {
+	body, err := l.s.fetch(docURL)
+	if err != nil {
+		return nil, err
+	}
+	return &located{url: docURL, body: body}, nil
+}
+
+// fetchPage downloads the page at the Parse URL once
+func (l *locator) fetchPage() ([]byte, http.Header, error) {
+	if !l.pageFetched {
+		l.page, l.pageHeader, l.pageErr = l.s.fetchLimited(l.pageURL, l.s.opts.MaxBytes)
+		l.pageFetched = true
+	}
+	return l.page, l.pageHeader, l.pageErr
+}
+
+// sitemap is the part of a sitemaps.org urlset we read
+type sitemap struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+func (l *locator) fromSitemap() (*located, error) {
+	mapURL, err := resolveReference(l.pageURL, "/sitemap.xml")
+	if err != nil {
+		return nil, err
+	}
+	body, err := l.s.fetch(mapURL)
+	if err != nil {
+		return nil, err
+	}
+	var sm sitemap
+	if err := xml.Unmarshal(body, &sm); err != nil {
+		return nil, fmt.Errorf("sitemap: %w", err)
+	}
+	for _, u := range sm.URLs {
+		loc := strings.TrimSpace(u.Loc)
+		if parsed, err := url.Parse(loc); err == nil && strings.HasSuffix(parsed.Path, ".aio") {
+			return l.fetchDocument(loc)
+		}
+	}
+	return nil, errors.New("sitemap lists no .aio document")
+}
+
+// linkTarget returns the target of the first link with relation rel in
+// the given Link header values (RFC 8288), or ""
+func linkTarget(values []string, rel string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok {
+				continue
+			}
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					if strings.EqualFold(r, rel) {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package aio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDirectURL(t *testing.T) {
+	tests := []struct {
+		page  string
+		index []string
+		want  string
+	}{
+		{page: "https://x.com", want: "https://x.com/ai-content.aio"},
+		{page: "https://x.com/", want: "https://x.com/ai-content.aio"},
+		{page: "https://x.com/docs", want: "https://x.com/docs/ai-content.aio"},
+		{page: "https://x.com/docs/", want: "https://x.com/docs/ai-content.aio"},
+		{page: "https://x.com/docs?v=2#top", want: "https://x.com/docs/ai-content.aio?v=2"},
+		{page: "https://x.com/docs/?v=2&lang=de", want: "https://x.com/docs/ai-content.aio?v=2&lang=de"},
+		{page: "https://x.com/docs/index.html", want: "https://x.com/docs/ai-content.aio"},
+		{page: "https://x.com/docs/INDEX.HTM#intro", want: "https://x.com/docs/ai-content.aio"},
+		{page: "https://x.com/my%20docs/", want: "https://x.com/my%20docs/ai-content.aio"},
+		{page: "https://x.com/a%2Fb/", want: "https://x.com/a%2Fb/ai-content.aio"},
+		{page: "https://x.com/docs/default.aspx", index: []string{"default.aspx"}, want: "https://x.com/docs/ai-content.aio"},
+		{page: "https://x.com/docs/index.html", index: []string{"default.aspx"}, want: "https://x.com/docs/index.html/ai-content.aio"},
+		{page: "https://x.com/docs/index.html", index: []string{}, want: "https://x.com/docs/index.html/ai-content.aio"},
+	}
+	for _, tt := range tests {
+		got, err := directURL(tt.page, tt.index)
+		if err != nil {
+			t.Errorf("directURL(%q, %q): %v", tt.page, tt.index, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("directURL(%q, %q) = %q, want %q", tt.page, tt.index, got, tt.want)
+		}
+	}
+	if _, err := directURL("https://x.com/%zz", nil); err == nil {
+		t.Error("directURL accepted an invalid escape")
+	}
+}
+
+func TestIndexDocumentsOption(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ai-content.aio" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"aio_version": "2.1", "content": [{"id": "a", "content": "Text."}]}`))
+	}))
+	defer srv.Close()
+	direct := []SourceStrategy{StrategyDirectURL}
+	opts := Options{AllowPrivateNetworks: true, FallbackChain: direct, IndexDocuments: []string{"default.aspx"}}
+	if _, err := ParseWithOptions(srv.URL+"/default.aspx?v=2", "", opts); err != nil {
+		t.Errorf("configured index page: %v", err)
+	}
+	opts.IndexDocuments = nil
+	if _, err := ParseWithOptions(srv.URL+"/default.aspx", "", opts); err == nil {
+		t.Error("default.aspx was taken as an index page without being configured")
+	}
+}
@@ -0,0 +1,34 @@
+package aio
+
+import "time"
+
+// isStale reports whether a chunk is past its freshness window at now.
+// The timestamp comes from the chunk's Updated field, falling back to the
+// index entry's LastModified and then the document's docUpdated; the
+// window is the chunk's TTL when set, else maxAge.
+func isStale(chunk Chunk, entry *IndexEntry, docUpdated string, maxAge time.Duration, now time.Time) bool {
+	updated, ok := chunkUpdated(chunk, entry, docUpdated)
+	if !ok {
+		return false
+	}
+
+	window := maxAge
+	if chunk.TTL > 0 {
+		window = time.Duration(chunk.TTL) * time.Second
+	}
+	return now.Sub(updated) > window
+}
+
+// chunkUpdated is when a chunk last changed, by the same fallbacks as
+// isStale, or false when nothing records it
+func chunkUpdated(chunk Chunk, entry *IndexEntry, docUpdated string) (time.Time, bool) {
+	stamp := chunk.Updated
+	if stamp == "" && entry != nil {
+		stamp = entry.LastModified
+	}
+	if stamp == "" {
+		stamp = docUpdated
+	}
+	updated, err := time.Parse(time.RFC3339, stamp)
+	return updated, err == nil
+}
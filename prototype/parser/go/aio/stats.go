@@ -0,0 +1,66 @@
+package aio
+
+import "strings"
+
+// Stats counts what happened to a document's chunks during a parse
+type Stats struct {
+	// TotalChunks is the size of the document's content array
+	TotalChunks int `json:"total_chunks"`
+
+	// Available is how many chunks the query and filters selected, and
+	// Matched how many of those fell in the Options.Offset/Count window;
+	// they differ only when a window is set
+	Available int `json:"available"`
+	Matched   int `json:"matched"`
+
+	// StaleSkipped counts chunks excluded as older than Options.MaxAge or
+	// their own TTL
+	StaleSkipped int `json:"stale_skipped,omitempty"`
+
+	// LanguageSkipped counts chunks excluded as in none of
+	// Options.Languages
+	LanguageSkipped int `json:"language_skipped,omitempty"`
+
+	// EmptySkipped counts selected chunks left out of the narrative because
+	// their content was empty or only whitespace
+	EmptySkipped int `json:"empty_skipped,omitempty"`
+
+	// BudgetDropped counts chunks (sentences, in sentence mode) that did
+	// not fit within Options.MaxTokens or MaxNarrativeBytes
+	BudgetDropped int `json:"budget_dropped,omitempty"`
+
+	// Summarized counts chunks Options.Summarizer shortened to fit
+	// MaxTokens
+	Summarized int `json:"summarized,omitempty"`
+
+	// KeywordFallback is set when Options.Retriever had no embeddings for
+	// the document and keyword ranking was used instead
+	KeywordFallback bool `json:"keyword_fallback,omitempty"`
+}
+
+// dropEmptyChunks removes chunks with no content beyond whitespace, which
+// would otherwise add stray separators to the narrative, and reports how
+// many it removed
+func dropEmptyChunks(chunks []Chunk) ([]Chunk, int) {
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		if strings.TrimSpace(chunk.Content) != "" {
+			kept = append(kept, chunk)
+		}
+	}
+	return kept, len(chunks) - len(kept)
+}
+
+// window returns count chunks starting at offset, or all from offset on
+// when count is zero or less
+func window(chunks []Chunk, offset, count int) []Chunk {
+	if offset <= 0 && count <= 0 {
+		return chunks
+	}
+	offset = min(max(offset, 0), len(chunks))
+	chunks = chunks[offset:]
+	if count > 0 && count < len(chunks) {
+		chunks = chunks[:count]
+	}
+	return chunks
+}
@@ -0,0 +1,73 @@
+package aio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmptyChunksAndStats(t *testing.T) {
+	doc := AIOFile{Version: "2.1", Content: []Chunk{
+		{ID: "a", Content: "Pricing is per seat."},
+		{ID: "empty", Content: ""},
+		{ID: "blank", Content: " \n\t "},
+		{ID: "b", Content: "Plans are monthly."},
+		{ID: "c", Content: "We are in Berlin."},
+	}}
+	data := mustEncode(t, &doc)
+	tests := []struct {
+		name  string
+		query string
+		opts  Options
+		ids   []string
+		stats Stats
+	}{
+		{
+			name:  "all chunks",
+			ids:   []string{"a", "b", "c"},
+			stats: Stats{TotalChunks: 5, Available: 5, Matched: 5, EmptySkipped: 2},
+		},
+		{
+			name:  "query",
+			query: "pricing",
+			ids:   []string{"a"},
+			stats: Stats{TotalChunks: 5, Available: 1, Matched: 1},
+		},
+		{
+			name:  "window over empty chunks",
+			opts:  Options{Offset: 1, Count: 3},
+			ids:   []string{"b"},
+			stats: Stats{TotalChunks: 5, Available: 5, Matched: 3, EmptySkipped: 2},
+		},
+		{
+			name:  "budget after empty chunks",
+			opts:  Options{MaxTokens: 8},
+			ids:   []string{"a"},
+			stats: Stats{TotalChunks: 5, Available: 5, Matched: 5, EmptySkipped: 2, BudgetDropped: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := parseAIO(strings.NewReader(string(data)), "", tt.query, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, c := range env.Items {
+				ids = append(ids, c.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("items = %q, want %q", ids, tt.ids)
+			}
+			if *env.Stats != tt.stats {
+				t.Errorf("stats = %+v, want %+v", *env.Stats, tt.stats)
+			}
+			if strings.Contains(env.Narrative, "\n\n\n") || strings.HasPrefix(env.Narrative, "\n") {
+				t.Errorf("stray separators in %q", env.Narrative)
+			}
+			if want := estimateTokens(env.Narrative); env.Tokens != want {
+				t.Errorf("Tokens = %d, want the %d of the narrative", env.Tokens, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package aio
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minStemLength is the fewest runes a stem may keep, so short words are
+// not cut down to fragments that match everything
+const minStemLength = 3
+
+// stemSuffixes are the commonest inflectional and derivational endings
+// of the languages stem handles, longest first. Stripping them without
+// restoring a base form is enough here: a stem matches the other forms of
+// its word as a substring, as query terms already match.
+var stemSuffixes = map[string][]string{
+	"en": {"ational", "fulness", "iveness", "ations", "ation", "ments", "ingly", "ness", "ings", "ment", "edly", "ing", "ies", "ied", "es", "ed", "ly", "s"},
+	"de": {"ungen", "heiten", "keiten", "ischen", "ung", "heit", "keit", "lich", "isch", "ern", "em", "en", "er", "es", "e", "n", "s"},
+	"fr": {"issements", "issement", "atrices", "ateurs", "ations", "ation", "ements", "ement", "euses", "euse", "ités", "ité", "ives", "ive", "ées", "ée", "és", "ifs", "aux", "if", "es", "er", "ez", "é", "s", "e"},
+	"es": {"amientos", "amiento", "aciones", "idades", "ación", "mente", "iendo", "ando", "idad", "ados", "adas", "ido", "ida", "ado", "ada", "es", "os", "as", "ar", "er", "ir", "o", "a", "s"},
+	"it": {"azioni", "azione", "amente", "mente", "ando", "endo", "ità", "ati", "ate", "ato", "ata", "ire", "are", "ere", "i", "e", "o", "a"},
+	"pt": {"amentos", "amento", "idades", "amente", "ações", "ação", "idade", "mente", "ando", "endo", "ados", "adas", "ões", "ado", "ada", "es", "os", "as", "ar", "er", "ir", "o", "a", "s"},
+	"nl": {"heden", "ingen", "heid", "lijk", "ing", "en", "er", "e", "s"},
+}
+
+// stem strips the longest suffix of word, already lower-cased, that the
+// language lang inflects with and that leaves at least minStemLength
+// runes. Words of languages without a stemmer are returned as they are.
+func stem(word, lang string) string {
+	suffixes := stemSuffixes[baseLanguage(lang)]
+	if suffixes == nil {
+		return word
+	}
+	for _, suffix := range suffixes {
+		s, ok := strings.CutSuffix(word, suffix)
+		if !ok || utf8.RuneCountInString(s) < minStemLength {
+			continue
+		}
+		if baseLanguage(lang) == "en" {
+			s = undouble(s)
+		}
+		return s
+	}
+	return word
+}
+
+// undouble drops the second of a doubled final consonant left by an
+// English suffix, "runn" of "running" to "run", except the l, s and z that
+// base forms end in, as in "fall" and "pass"
+func undouble(s string) string {
+	n := len(s)
+	if n < 2 || s[n-1] != s[n-2] || strings.IndexByte("aeiouylsz", s[n-1]) >= 0 {
+		return s
+	}
+	return s[:n-1]
+}
+
+// baseLanguage is the primary subtag of a language tag, lower-cased: "pt"
+// of "pt-BR"
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	return base
+}
+
+// lowerFor lower-cases text by the rules of lang: Turkish and Azerbaijani
+// map I to dotless ı and İ to i, where other languages map I to i
+func lowerFor(lang string) func(string) string {
+	switch baseLanguage(lang) {
+	case "tr", "az":
+		return func(s string) string { return strings.ToLowerSpecial(unicode.TurkishCase, s) }
+	}
+	return strings.ToLower
+}
@@ -0,0 +1,34 @@
+package aio
+
+// DefaultStopwords is the built-in English stopword list used by
+// Options.RemoveStopwords
+var DefaultStopwords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "do", "does",
+	"for", "from", "has", "have", "how", "i", "if", "in", "into", "is",
+	"it", "its", "me", "my", "no", "not", "of", "on", "or", "our", "so",
+	"that", "the", "their", "then", "there", "these", "they", "this",
+	"to", "was", "we", "what", "when", "where", "which", "who", "why",
+	"will", "with", "you", "your",
+}
+
+// removeStopwords drops plain terms found in stopwords. Wildcard and
+// boosted terms are always kept, since they were written deliberately.
+// If every term is a stopword the query is returned unchanged rather than
+// collapsing into an empty query that selects everything.
+func removeStopwords(terms []queryTerm, stopwords []string) []queryTerm {
+	stop := make(map[string]bool, len(stopwords))
+	for _, w := range stopwords {
+		stop[w] = true
+	}
+
+	var kept []queryTerm
+	for _, t := range terms {
+		if t.glob || t.boost != 1 || !stop[t.text] {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		return terms
+	}
+	return kept
+}
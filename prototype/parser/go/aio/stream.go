@@ -0,0 +1,52 @@
+package aio
+
+import "context"
+
+// StreamEvent carries one block of the assembled narrative, or one chunk
+// in items-only mode
+type StreamEvent struct {
+	// Index is the position of the block in the narrative, from zero
+	Index    int      `json:"index"`
+	ChunkIDs []string `json:"chunk_ids"`
+
+	// Content is the block as it appears in the narrative
+	Content string `json:"content"`
+}
+
+// ParseStream parses url like ParseContext, then hands each block of the
+// finished narrative to emit in order, so a caller can forward it block by
+// block, as /parse/stream does. The blocks only exist once the token
+// budget has ranked and selected every chunk, so none is emitted before
+// the whole parse is done. An error from emit stops the events and is
+// returned.
+func (p *Parser) ParseStream(ctx context.Context, url string, query string, emit func(StreamEvent) error) (*ContentEnvelope, error) {
+	env, err := p.ParseContext(ctx, url, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, ev := range streamEvents(env) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := emit(ev); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+// streamEvents splits an envelope into the events ParseStream emits
+func streamEvents(env *ContentEnvelope) []StreamEvent {
+	if env.Narrative == "" {
+		events := make([]StreamEvent, len(env.Items))
+		for i, c := range env.Items {
+			events[i] = StreamEvent{Index: i, ChunkIDs: []string{c.ID}, Content: c.Content}
+		}
+		return events
+	}
+	events := make([]StreamEvent, len(env.Spans))
+	for i, s := range env.Spans {
+		events[i] = StreamEvent{Index: i, ChunkIDs: s.ChunkIDs, Content: env.Narrative[s.Start:s.End]}
+	}
+	return events
+}
@@ -0,0 +1,46 @@
+package aio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseStream(t *testing.T) {
+	srv := newAuthRecorder(t, func() string {
+		return `{"aio_version": "2.1", "content": [{"id": "one", "content": "First."}, {"id": "two", "content": "Second."}]}`
+	})
+	p := &Parser{Options: Options{AllowPrivateNetworks: true, IgnoreRobots: true}}
+	var got []StreamEvent
+	env, err := p.ParseStream(context.Background(), srv.URL+"/", "", func(ev StreamEvent) error {
+		got = append(got, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(env.Spans) || len(got) != 2 {
+		t.Fatalf("got %d events for %d spans, want 2", len(got), len(env.Spans))
+	}
+	for i, ev := range got {
+		if s := env.Spans[i]; ev.Index != i || ev.Content != env.Narrative[s.Start:s.End] {
+			t.Errorf("event %d = %+v, want span %+v", i, ev, s)
+		}
+	}
+
+	stop := errors.New("stop")
+	if _, err := p.ParseStream(context.Background(), srv.URL+"/", "", func(StreamEvent) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("err = %v, want the error from emit", err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewHandler(p).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/parse/stream?url="+url.QueryEscape(srv.URL+"/"), nil))
+	body := rec.Body.String()
+	if strings.Count(body, "event: chunk") != 2 || !strings.Contains(body, "event: done") {
+		t.Errorf("stream = %q, want two chunk events and done", body)
+	}
+}
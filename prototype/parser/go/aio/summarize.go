@@ -0,0 +1,165 @@
+package aio
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Summarizer shortens the selected chunks when together they exceed
+// Options.MaxTokens, so the narrative keeps something of each rather than
+// dropping the lowest ranked whole (Options.Summarizer). It returns the
+// chunks to assemble, in the order given, each still carrying the ID,
+// Citation and Provenance of the chunk it shortens; a chunk may also be
+// left out. Whatever still does not fit is dropped by the budget as
+// before. Summarize is called from concurrent parses.
+type Summarizer interface {
+	Summarize(ctx context.Context, req SummaryRequest) ([]Chunk, error)
+}
+
+// SummarizerFunc adapts a function to Summarizer, e.g. one prompting a
+// language model to condense each chunk
+type SummarizerFunc func(ctx context.Context, req SummaryRequest) ([]Chunk, error)
+
+func (f SummarizerFunc) Summarize(ctx context.Context, req SummaryRequest) ([]Chunk, error) {
+	return f(ctx, req)
+}
+
+// SummaryRequest is what a Summarizer compresses
+type SummaryRequest struct {
+	Query string
+
+	// Chunks are the selected chunks in rank order
+	Chunks []Chunk
+
+	// MaxTokens is the budget for the narrative, in which each chunk
+	// costs Tokens of its content followed by a blank line
+	MaxTokens int
+	Tokens    func(text string) int
+}
+
+// ExtractiveSummarizer is a Summarizer that keeps the best sentences of
+// each chunk: those matching the most query terms, and without a query
+// the opening ones. Each chunk is shortened in proportion to its share of
+// the content, keeping at least its best sentence, and its sentences stay
+// in reading order.
+type ExtractiveSummarizer struct{}
+
+func (ExtractiveSummarizer) Summarize(ctx context.Context, req SummaryRequest) ([]Chunk, error) {
+	total := 0
+	for _, c := range req.Chunks {
+		total += req.Tokens(c.Content + chunkSeparator)
+	}
+	if total <= req.MaxTokens {
+		return req.Chunks, nil
+	}
+
+	terms := parseTerms(req.Query)
+	ratio := float64(req.MaxTokens) / float64(total)
+	out := make([]Chunk, len(req.Chunks))
+	for i, c := range req.Chunks {
+		share := int(ratio * float64(req.Tokens(c.Content+chunkSeparator)))
+		c.Content = extractSentences(c.Content, terms, share, req.Tokens)
+		out[i] = c
+	}
+	return out, nil
+}
+
+// extractSentences keeps the best sentences of text that fit within
+// budget tokens, always at least one, joined in their original order.
+// Text of a single sentence is returned as it is.
+func extractSentences(text string, terms []queryTerm, budget int, tokens func(string) int) string {
+	sentences := splitSentences(text)
+	if len(sentences) <= 1 {
+		return text
+	}
+	scores := make([]float64, len(sentences))
+	for i, s := range sentences {
+		lower := strings.ToLower(s)
+		for _, term := range terms {
+			if term.matches(lower) {
+				scores[i] += term.boost
+			}
+		}
+	}
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	// Ties, and every sentence without a query, go to the earlier one
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	// Sentences are costed one at a time rather than by re-estimating the
+	// growing summary, which made long chunks quadratic. Estimates that
+	// round down can sum to less than the joined text costs, so when it
+	// does not fit the most of the kept sentences, in rank order, that do
+	// are found by bisection.
+	keep := make([]bool, len(sentences))
+	used := tokens(chunkSeparator)
+	var kept []int
+	for n, i := range order {
+		cost := tokens(sentences[i] + " ")
+		if n > 0 && used+cost > budget {
+			continue
+		}
+		keep[i] = true
+		used += cost
+		kept = append(kept, i)
+	}
+	fits := func(n int) bool {
+		clear(keep)
+		for _, i := range kept[:n] {
+			keep[i] = true
+		}
+		return tokens(joinKept(sentences, keep)+chunkSeparator) <= budget
+	}
+	if !fits(len(kept)) {
+		fits(max(sort.Search(len(kept), func(n int) bool { return !fits(n + 1) }), 1))
+	}
+	return joinKept(sentences, keep)
+}
+
+func joinKept(sentences []string, keep []bool) string {
+	var kept []string
+	for i, s := range sentences {
+		if keep[i] {
+			kept = append(kept, s)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// summarizeChunks passes chunks over the token budget through
+// opts.Summarizer, marking those it shortened. A failing Summarizer is
+// logged, leaving the budget to drop chunks as it would without one.
+func summarizeChunks(chunks []Chunk, query string, opts Options, estimate tokenEstimator, run *parseRun) ([]Chunk, int) {
+	if opts.Summarizer == nil || opts.MaxTokens <= 0 {
+		return chunks, 0
+	}
+	total := 0
+	for _, c := range chunks {
+		total += chunkTokens(c, estimate)
+	}
+	if total <= opts.MaxTokens {
+		return chunks, 0
+	}
+
+	original := make(map[string]string, len(chunks))
+	for _, c := range chunks {
+		original[c.ID] = c.Content
+	}
+	req := SummaryRequest{Query: query, Chunks: append([]Chunk(nil), chunks...), MaxTokens: opts.MaxTokens, Tokens: estimate}
+	summarized, err := opts.Summarizer.Summarize(run.ctx, req)
+	if err != nil {
+		run.logf("aio: summarizing: %v", err)
+		return chunks, 0
+	}
+	shortened := 0
+	for i := range summarized {
+		if c := &summarized[i]; c.Content != original[c.ID] {
+			c.Summarized = true
+			shortened++
+		}
+	}
+	return summarized, shortened
+}
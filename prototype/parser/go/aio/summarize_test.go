@@ -0,0 +1,61 @@
+package aio
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func FuzzSummarize(f *testing.F) {
+	f.Add("Pricing is per seat. Plans are monthly. We are in Berlin.", "pricing", 5)
+	f.Add("One sentence only", "", 1)
+	f.Add("A.\n\nB! C? D.", "b c", 0)
+	f.Fuzz(func(t *testing.T, content, query string, maxTokens int) {
+		req := SummaryRequest{
+			Query:     query,
+			Chunks:    []Chunk{{ID: "a", Content: content}, {ID: "b", Content: content + " " + content}},
+			MaxTokens: maxTokens,
+			Tokens:    estimatorFor(""),
+		}
+		out, err := ExtractiveSummarizer{}.Summarize(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != len(req.Chunks) {
+			t.Fatalf("got %d chunks for %d", len(out), len(req.Chunks))
+		}
+		for i, c := range out {
+			if c.ID != req.Chunks[i].ID {
+				t.Errorf("chunk %d has ID %q, want %q", i, c.ID, req.Chunks[i].ID)
+			}
+			if len(c.Content) > len(req.Chunks[i].Content) {
+				t.Errorf("summary of chunk %d is longer than the chunk: %q", i, c.Content)
+			}
+		}
+	})
+}
+
+func BenchmarkSummarizeScaling(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("sentences=%d", n), func(b *testing.B) {
+			var text strings.Builder
+			for i := 0; i < n; i++ {
+				fmt.Fprintf(&text, "Sentence %d mentions pricing%d. ", i, i%7)
+			}
+			req := SummaryRequest{
+				Query:     "pricing3 pricing5",
+				Chunks:    []Chunk{{ID: "a", Content: text.String()}},
+				MaxTokens: 200,
+				Tokens:    estimatorFor(""),
+			}
+			b.SetBytes(int64(text.Len()))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := (ExtractiveSummarizer{}).Summarize(context.Background(), req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package aio
+
+import "strings"
+
+// hasTags reports whether the chunk carries the wanted tags: all of them
+// when all is set, else any one. A chunk's category counts as one of its
+// tags, and comparison ignores case.
+func hasTags(c Chunk, want []string, all bool) bool {
+	for _, w := range want {
+		found := strings.EqualFold(c.Category, w)
+		for _, t := range c.Tags {
+			if found {
+				break
+			}
+			found = strings.EqualFold(t, w)
+		}
+		if found && !all {
+			return true
+		}
+		if !found && all {
+			return false
+		}
+	}
+	return all
+}
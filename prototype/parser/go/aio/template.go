@@ -0,0 +1,66 @@
+package aio
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is what HeaderTemplate and FooterTemplate render against:
+// every envelope field plus the retrieval time, e.g.
+// "Content from {{.SourceURL}} retrieved {{.Time.Format \"2006-01-02\"}}:"
+type templateData struct {
+	*ContentEnvelope
+	Time time.Time
+}
+
+// frameNarrative wraps env.Narrative with the rendered header and footer
+// templates, each set off from the chunk content by the chunk separator,
+// and shifts Spans past the header
+func frameNarrative(env *ContentEnvelope, opts Options) error {
+	if opts.HeaderTemplate == "" && opts.FooterTemplate == "" {
+		return nil
+	}
+
+	data := templateData{ContentEnvelope: env, Time: time.Now()}
+	header, err := renderTemplate("header", opts.HeaderTemplate, data)
+	if err != nil {
+		return err
+	}
+	footer, err := renderTemplate("footer", opts.FooterTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if header != "" {
+		b.WriteString(header)
+		b.WriteString(chunkSeparator)
+		for i := range env.Spans {
+			env.Spans[i].Start += b.Len()
+			env.Spans[i].End += b.Len()
+		}
+	}
+	b.WriteString(env.Narrative)
+	if footer != "" {
+		b.WriteString(footer)
+		b.WriteString(chunkSeparator)
+	}
+	env.Narrative = b.String()
+	return nil
+}
+
+func renderTemplate(name, text string, data templateData) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,17 @@
+package aio
+
+import "time"
+
+// Timing breaks a parse down by phase. Durations encode as nanoseconds.
+type Timing struct {
+	Discovery time.Duration `json:"discovery_ns"`
+	Network   time.Duration `json:"network_ns"`
+	Decode    time.Duration `json:"decode_ns"`
+	Selection time.Duration `json:"selection_ns"`
+	Assembly  time.Duration `json:"assembly_ns"`
+}
+
+// Total is the sum of all phases
+func (t *Timing) Total() time.Duration {
+	return t.Discovery + t.Network + t.Decode + t.Selection + t.Assembly
+}
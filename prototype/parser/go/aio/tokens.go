@@ -0,0 +1,71 @@
+package aio
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenEstimator approximates the token count of text
+type tokenEstimator func(text string) int
+
+// estimateTokens is the language-agnostic heuristic of ~4 bytes per token
+func estimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// charsPerTokenEstimator counts tokens as len(text)/n, generalizing
+// estimateTokens to models with a different average token length
+func charsPerTokenEstimator(n float64) tokenEstimator {
+	return func(text string) int {
+		return int(float64(len(text)) / n)
+	}
+}
+
+// estimatorFor picks a token estimator for a declared language code
+// (ISO 639-1, optionally with a region such as "zh-TW"). Unknown or empty
+// languages use the generic estimator.
+func estimatorFor(lang string) tokenEstimator {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(lang)), "-")
+	switch {
+	case base == "":
+		return estimateTokens
+	case cjkLanguages[base]:
+		return estimateCJKTokens
+	case latinLanguages[base]:
+		return estimateWordTokens
+	default:
+		return estimateTokens
+	}
+}
+
+// cjkLanguages are written mostly without spaces, where tokenizers spend
+// roughly a token per character
+var cjkLanguages = map[string]bool{"zh": true, "ja": true, "ko": true}
+
+// latinLanguages are space-delimited and average about 0.75 words per token
+var latinLanguages = map[string]bool{
+	"en": true, "de": true, "fr": true, "es": true, "it": true, "pt": true,
+	"nl": true, "sv": true, "da": true, "no": true, "nb": true, "fi": true,
+	"pl": true, "cs": true, "ro": true, "hu": true, "tr": true, "id": true,
+}
+
+// estimateWordTokens counts words and scales by 4/3 tokens per word
+func estimateWordTokens(text string) int {
+	return len(strings.Fields(text)) * 4 / 3
+}
+
+// estimateCJKTokens counts one token per Han, Kana or Hangul character and
+// estimates any interleaved Latin text by words
+func estimateCJKTokens(text string) int {
+	chars := 0
+	var rest strings.Builder
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			chars++
+			rest.WriteRune(' ')
+			continue
+		}
+		rest.WriteRune(r)
+	}
+	return chars + estimateWordTokens(rest.String())
+}
@@ -0,0 +1,31 @@
+package aio
+
+// applyTransforms runs each chunk through the transform pipeline in order,
+// dropping chunks that a transform empties
+func applyTransforms(chunks []Chunk, transforms []func(Chunk) Chunk) []Chunk {
+	if len(transforms) == 0 {
+		return chunks
+	}
+
+	var out []Chunk
+	for _, chunk := range chunks {
+		dropped := false
+		for _, transform := range transforms {
+			chunk = transform(chunk)
+			if isEmptyChunk(chunk) {
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			out = append(out, chunk)
+		}
+	}
+	return out
+}
+
+// isEmptyChunk reports whether a transform returned an empty Chunk;
+// a chunk with neither ID nor content has nothing left to assemble
+func isEmptyChunk(c Chunk) bool {
+	return c.ID == "" && c.Content == ""
+}
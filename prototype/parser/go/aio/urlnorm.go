@@ -0,0 +1,42 @@
+package aio
+
+import (
+	"net/url"
+	"strings"
+)
+
+// normalizeURL rewrites equivalent spellings of a URL to one form, so
+// they share cache entries and are fetched once: the scheme and host are
+// lower-cased, the scheme's default port, the fragment and a trailing
+// slash are dropped, and query parameters are sorted by key. The path
+// keeps its case, which servers may treat as significant. A URL that does
+// not parse is returned unchanged.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment, u.RawFragment = "", ""
+
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+	u.ForceQuery = false
+	return u.String()
+}
@@ -0,0 +1,226 @@
+package aio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The aio_version range the parser is written against; Capabilities
+// reports it and Validate checks documents against it
+const (
+	minVersion = "2.0"
+	maxVersion = "2.1"
+)
+
+// Severity grades a validation Issue
+type Severity string
+
+const (
+	// SeverityError marks a problem that makes the document wrong: parses
+	// of it may fail or silently return the wrong content
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks something likely unintended that the parser
+	// copes with
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is one problem Validate found in a document
+type Issue struct {
+	Severity Severity `json:"severity"`
+
+	// Field locates the problem, e.g. "content[3].hash"
+	Field string `json:"field"`
+
+	// ChunkID names the chunk concerned, when there is one
+	ChunkID string `json:"chunk_id,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+}
+
+// HasErrors reports whether any of issues is an error rather than a
+// warning
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDocument decodes the raw bytes of an .aio document and
+// validates it. A document that does not decode yields a single error.
+// A document in an older layout is validated as migrated, after a warning
+// that it was.
+func ValidateDocument(data []byte) []Issue {
+	data, err := unpack(data, 0)
+	var version *VersionInfo
+	if err == nil {
+		data, version, err = normalizeVersion(trimLeadingNoise(data))
+	}
+	if errors.Is(err, ErrUnsupportedVersion) {
+		// Validate reports the version; the rest is checked as it stands
+		err = nil
+	}
+	if err == nil {
+		err = checkShape(data)
+	}
+	var aio AIOFile
+	if err == nil {
+		err = json.Unmarshal(data, &aio)
+	}
+	if err != nil {
+		issue := Issue{Severity: SeverityError, Field: "$", Message: err.Error()}
+		var se *ShapeError
+		if errors.As(shapeError(err), &se) {
+			issue.Field = se.Field
+			issue.Message = fmt.Sprintf("must be %s, got %s", se.Want, se.Got)
+		}
+		return []Issue{issue}
+	}
+	issues := Validate(&aio)
+	if version != nil && version.Migrated {
+		migrated := Issue{Severity: SeverityWarning, Field: "aio_version", Message: fmt.Sprintf("document is in the deprecated 1.x layout; it is migrated to %s as it is read", version.Negotiated)}
+		issues = append([]Issue{migrated}, issues...)
+	}
+	return issues
+}
+
+// Validate checks a decoded document for an unsupported aio_version,
+// malformed metadata dates and canonical URLs, missing or duplicate chunk
+// IDs, empty content, missing, malformed or mismatched hashes, unresolved
+// {{ref:...}} transclusions, index entries naming no chunk and child
+// documents without a URL. Issues are listed in document order; a
+// document without issues returns nil.
+func Validate(a *AIOFile) []Issue {
+	var issues []Issue
+	add := func(sev Severity, field, chunkID, format string, args ...any) {
+		issues = append(issues, Issue{Severity: sev, Field: field, ChunkID: chunkID, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if sev, msg := checkVersion(a.Version); msg != "" {
+		add(sev, "aio_version", "", "%s", msg)
+	}
+	if len(a.Content) == 0 && len(a.Documents) == 0 {
+		add(SeverityWarning, "content", "", "document has no chunks")
+	}
+	for i, d := range a.Documents {
+		if d.URL == "" {
+			add(SeverityError, fmt.Sprintf("documents[%d].url", i), "", "child document has no url")
+		}
+	}
+	if m := a.Metadata; m != nil {
+		if _, err := time.Parse(time.RFC3339, m.LastUpdated); m.LastUpdated != "" && err != nil {
+			add(SeverityWarning, "metadata.last_updated", "", "%q is not an RFC 3339 timestamp", m.LastUpdated)
+		}
+		if u, err := url.Parse(m.CanonicalURL); m.CanonicalURL != "" && (err != nil || !u.IsAbs()) {
+			add(SeverityWarning, "metadata.canonical_url", "", "%q is not an absolute URL", m.CanonicalURL)
+		}
+	}
+
+	ids := make(map[string]int, len(a.Content))
+	for i, c := range a.Content {
+		field := fmt.Sprintf("content[%d]", i)
+		switch first, dup := ids[c.ID]; {
+		case c.ID == "":
+			add(SeverityError, field+".id", "", "chunk has no id")
+		case dup:
+			add(SeverityError, field+".id", c.ID, "duplicate chunk id, first used by content[%d]", first)
+		default:
+			ids[c.ID] = i
+		}
+
+		if strings.TrimSpace(c.Content) == "" {
+			add(SeverityWarning, field+".content", c.ID, "chunk content is empty")
+		}
+
+		if strings.TrimSpace(c.Hash) == "" {
+			add(SeverityWarning, field+".hash", c.ID, "chunk has no hash, so its integrity cannot be verified")
+			continue
+		}
+		algo, digest, err := parseHash(c.Hash, c.HashAlgo)
+		switch {
+		case err != nil:
+			add(SeverityError, field+".hash", c.ID, "%v", err)
+		case digest == "":
+			add(SeverityError, field+".hash", c.ID, "hash %q is not hex of the algorithm's digest size", c.Hash)
+		case digestHex(algo, c.Content) != digest:
+			add(SeverityError, field+".hash", c.ID, "hash does not match the chunk content")
+		}
+	}
+
+	for i, c := range a.Content {
+		for _, m := range refPattern.FindAllStringSubmatch(c.Content, -1) {
+			if _, ok := ids[m[1]]; !ok {
+				add(SeverityWarning, fmt.Sprintf("content[%d].content", i), c.ID, "reference {{ref:%s}} names no chunk", m[1])
+			}
+		}
+	}
+
+	indexed := make(map[string]int, len(a.Index))
+	for i, e := range a.Index {
+		field := fmt.Sprintf("index[%d].id", i)
+		if first, dup := indexed[e.ID]; dup {
+			add(SeverityWarning, field, e.ID, "chunk already indexed by index[%d]", first)
+			continue
+		}
+		indexed[e.ID] = i
+		if _, ok := ids[e.ID]; !ok {
+			add(SeverityError, field, e.ID, "index entry names no chunk")
+		}
+	}
+	return issues
+}
+
+// checkVersion compares an aio_version with the supported range,
+// returning an empty message when it is within it
+func checkVersion(v string) (Severity, string) {
+	if v == "" {
+		return SeverityError, "missing aio_version"
+	}
+	major, minor, ok := splitVersion(v)
+	if !ok {
+		return SeverityError, fmt.Sprintf("aio_version %q is not of the form MAJOR.MINOR", v)
+	}
+	minMajor, minMinor, _ := splitVersion(minVersion)
+	maxMajor, maxMinor, _ := splitVersion(maxVersion)
+	if m, ok := migrationFrom(major); ok {
+		return SeverityWarning, fmt.Sprintf("aio_version %s is deprecated; documents are migrated to %s as they are read", v, m.to)
+	}
+	switch {
+	case major != maxMajor:
+		return SeverityError, fmt.Sprintf("aio_version %s is incompatible; this parser reads %s", v, readableVersions())
+	case major == minMajor && minor < minMinor:
+		return SeverityError, fmt.Sprintf("aio_version %s predates the oldest supported version %s", v, minVersion)
+	case minor > maxMinor:
+		return SeverityWarning, fmt.Sprintf("aio_version %s is newer than %s; fields added since are ignored", v, maxVersion)
+	}
+	return "", ""
+}
+
+// splitVersion parses "MAJOR.MINOR", allowing a trailing ".PATCH"
+func splitVersion(v string) (major, minor int, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, false
+	}
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		nums[i] = n
+	}
+	return nums[0], nums[1], true
+}
@@ -0,0 +1,207 @@
+package aio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedVersion is returned for a document whose aio_version the
+// parser neither reads nor knows how to migrate
+var ErrUnsupportedVersion = errors.New("aio: unsupported aio_version")
+
+// VersionInfo records the aio_version a document declared and the version
+// of the layout the parser read it as
+type VersionInfo struct {
+	// Declared is the document's aio_version, empty when it has none
+	Declared string `json:"declared,omitempty"`
+
+	// Negotiated is the version the document was read as: Declared, as
+	// MAJOR.MINOR, within the supported range; the newest supported version
+	// for a newer minor version or a document without aio_version; and the
+	// version the last migration produced for an older layout
+	Negotiated string `json:"negotiated"`
+
+	// Migrated is set when the document was converted from an older layout
+	Migrated bool `json:"migrated,omitempty"`
+}
+
+// migration converts the top-level fields of a document in one major
+// version's layout to the next one's
+type migration struct {
+	from    int    // the major version converted
+	to      string // the version produced
+	convert func(top map[string]json.RawMessage) (map[string]json.RawMessage, error)
+}
+
+// migrations are the converters from older layouts, in version order
+var migrations = []migration{
+	{from: 1, to: "2.0", convert: migrateSidecar},
+}
+
+// migrate negotiates the version of a document from its top-level fields
+// and converts them to the current layout when they are in an older one
+func migrate(top map[string]json.RawMessage) (map[string]json.RawMessage, *VersionInfo, error) {
+	var declared string
+	if raw, ok := top["aio_version"]; ok && jsonType(raw) != "null" {
+		if err := json.Unmarshal(raw, &declared); err != nil {
+			return nil, nil, &ShapeError{Field: "aio_version", Want: "a string", Got: jsonType(raw)}
+		}
+	}
+	chain, info, err := negotiateVersion(declared, isSidecar(top))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range chain {
+		if top, err = m.convert(top); err != nil {
+			return nil, nil, fmt.Errorf("migrating aio_version %d.x to %s: %w", m.from, m.to, err)
+		}
+	}
+	return top, info, nil
+}
+
+// negotiateVersion decides how a document declaring aio_version declared
+// is read, returning the migrations it needs. sidecar is set for a
+// document in the per-page layout, which some early 2.0 publishers wrote
+// too, so it is migrated whatever version it declares.
+func negotiateVersion(declared string, sidecar bool) ([]migration, *VersionInfo, error) {
+	maxMajor, maxMinor, _ := splitVersion(maxVersion)
+	info := &VersionInfo{Declared: declared, Negotiated: maxVersion}
+	major := maxMajor
+	if declared != "" {
+		var minor int
+		var ok bool
+		if major, minor, ok = splitVersion(declared); !ok {
+			return nil, nil, fmt.Errorf("%w: %q is not of the form MAJOR.MINOR", ErrUnsupportedVersion, declared)
+		}
+		if major == maxMajor && minor <= maxMinor {
+			info.Negotiated = fmt.Sprintf("%d.%d", major, minor)
+		}
+	}
+	if sidecar && major == maxMajor {
+		major = migrations[0].from
+	}
+
+	var chain []migration
+	for _, m := range migrations {
+		if m.from == major {
+			chain = append(chain, m)
+			major, _, _ = splitVersion(m.to)
+			info.Negotiated, info.Migrated = m.to, true
+		}
+	}
+	if major != maxMajor {
+		return nil, nil, fmt.Errorf("%w: %s (this parser reads %s)", ErrUnsupportedVersion, declared, readableVersions())
+	}
+	return chain, info, nil
+}
+
+// readableVersions describes the versions the parser reads, e.g. "2.x
+// and migrates 1.x"
+func readableVersions() string {
+	maxMajor, _, _ := splitVersion(maxVersion)
+	s := fmt.Sprintf("%d.x", maxMajor)
+	if len(migrations) == 0 {
+		return s
+	}
+	older := make([]string, len(migrations))
+	for i, m := range migrations {
+		older[i] = fmt.Sprintf("%d.x", m.from)
+	}
+	return s + " and migrates " + strings.Join(older, ", ")
+}
+
+// migrationFrom returns the migration of a major version, if it has one
+func migrationFrom(major int) (migration, bool) {
+	for _, m := range migrations {
+		if m.from == major {
+			return m, true
+		}
+	}
+	return migration{}, false
+}
+
+// isSidecar reports whether a document is in the per-page sidecar layout
+// of 1.x: one page's text under payload.content, with no content array
+func isSidecar(top map[string]json.RawMessage) bool {
+	_, payload := top["payload"]
+	_, content := top["content"]
+	return payload && !content
+}
+
+// sidecarPayload is the payload object of a 1.x document
+type sidecarPayload struct {
+	MimeType string `json:"mime_type"`
+	Content  string `json:"content"`
+}
+
+// migrateSidecar converts a 1.x document to 2.0. A per-page sidecar
+// becomes one chunk named after its title, with an index entry; its
+// instructions and notices to agents are dropped and its metadata kept.
+// A 1.x document already holding a content array is only relabeled.
+func migrateSidecar(top map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	out := make(map[string]json.RawMessage, len(top))
+	for k, v := range top {
+		switch k {
+		case "payload", "instructions", "system_notice":
+		default:
+			out[k] = v
+		}
+	}
+	out["aio_version"] = json.RawMessage(`"2.0"`)
+	if !isSidecar(top) {
+		return out, nil
+	}
+
+	var payload sidecarPayload
+	if got := jsonType(top["payload"]); got != "an object" {
+		return nil, &ShapeError{Field: "payload", Want: "an object", Got: got}
+	}
+	if err := json.Unmarshal(top["payload"], &payload); err != nil {
+		return nil, shapeError(err)
+	}
+	var meta Metadata
+	if raw, ok := top["metadata"]; ok {
+		json.Unmarshal(raw, &meta)
+	}
+	id := slugify(meta.Title)
+	if id == "" {
+		id = "page"
+	}
+	format := "markdown"
+	if payload.MimeType == "text/plain" {
+		format = "plain"
+	}
+
+	content, err := json.Marshal([]Chunk{{ID: id, Format: format, Content: payload.Content}})
+	if err != nil {
+		return nil, err
+	}
+	index, err := json.Marshal([]IndexEntry{{ID: id, Title: meta.Title, Keywords: []string{}}})
+	if err != nil {
+		return nil, err
+	}
+	out["content"], out["index"] = content, index
+	return out, nil
+}
+
+// normalizeVersion negotiates the version of an encoded document,
+// returning it re-encoded in the current layout when it was migrated and
+// unchanged otherwise. A document that is not an object is returned as it
+// is for checkShape to report.
+func normalizeVersion(data []byte) ([]byte, *VersionInfo, error) {
+	if jsonType(data) != "an object" {
+		return data, nil, nil
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return data, nil, nil
+	}
+	migrated, info, err := migrate(top)
+	if err != nil || !info.Migrated {
+		return data, info, err
+	}
+	data, err = json.Marshal(migrated)
+	return data, info, err
+}
@@ -0,0 +1,105 @@
+package aio
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookSignatureHeader carries the HMAC-SHA256 of a webhook body under
+// Options.WebhookSecret, as "sha256=" and the hex digest. Receivers
+// recompute it over the raw body and compare in constant time.
+const WebhookSignatureHeader = "X-AIO-Signature"
+
+// webhookBackoff is the wait before the first webhook retry; each later
+// retry waits twice as long as the one before
+const webhookBackoff = time.Second
+
+// notify posts env to Options.WebhookURL in the background. Delivery
+// outlives the parse's context, and its failures only reach the loggers.
+func (p *Parser) notify(env *ContentEnvelope) {
+	if p.Options.WebhookURL == "" {
+		return
+	}
+	go func() {
+		body, err := json.Marshal(env)
+		if err == nil {
+			err = p.deliver(context.Background(), body)
+		}
+		if err != nil {
+			p.logf("aio: webhook for %s: %v", env.SourceURL, err)
+		}
+	}()
+}
+
+// deliver POSTs body to the webhook, retrying network errors, 429 and 5xx
+// responses up to Options.WebhookRetries times with exponential backoff
+func (p *Parser) deliver(ctx context.Context, body []byte) error {
+	opts := p.Options
+	client := p.Client
+	if client == nil {
+		client = newHTTPClient(opts)
+	}
+	wait := webhookBackoff
+	for attempt := 0; ; attempt++ {
+		err := postWebhook(ctx, client, opts, body)
+		if err == nil || !webhookRetryable(err) || attempt >= opts.WebhookRetries {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+// webhookStatusError is a webhook response other than 2xx
+type webhookStatusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("POST %s: %s", e.url, e.status)
+}
+
+// webhookRetryable reports whether a failed delivery may succeed if repeated
+func webhookRetryable(err error) bool {
+	se, ok := err.(*webhookStatusError)
+	if !ok {
+		return true // the request never got an answer
+	}
+	return se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
+func postWebhook(ctx context.Context, client *http.Client, opts Options, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent(opts))
+	if len(opts.WebhookSecret) > 0 {
+		mac := hmac.New(sha256.New, opts.WebhookSecret)
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{url: opts.WebhookURL, status: resp.Status, code: resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aio-parser-go/aio"
+)
+
+// runConformance implements "aio conformance [-update] [-json] <dir>". It
+// exits 0 when every fixture passes, 1 when any fails and 2 when the
+// corpus could not be read.
+func runConformance(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	update := fs.Bool("update", false, "write the golden envelopes of fixtures that parse instead of comparing them")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio conformance [-update] [-json] <dir>")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	results, err := aio.RunConformance(args[0], *update)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+	if *asJSON {
+		printJSON(results)
+	} else {
+		for _, r := range results {
+			status := "ok  "
+			switch {
+			case !r.Passed:
+				status = "FAIL"
+			case r.Updated:
+				status = "upd "
+			}
+			fmt.Printf("%s %s\n", status, r.Name)
+			for _, f := range r.Failures {
+				fmt.Printf("     %s\n", f)
+			}
+		}
+		fmt.Printf("%d of %d fixtures passed\n", len(results)-failed, len(results))
+	}
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"aio-parser-go/aio"
+)
+
+// runExport implements "aio export -sink sqlite|jsonl|http [flags] <url>",
+// writing the chunks a site publishes, or those matching -query, to a RAG
+// pipeline's store. It exits 0 once they are exported, 3 when a query
+// matched none of them, and otherwise as fetch does when the document
+// could not be parsed; an export that fails exits 1.
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	sink := fs.String("sink", "", "where to export: sqlite (an FTS5 script for sqlite3), jsonl or http")
+	query := fs.String("query", "", "keywords selecting the chunks to export; empty exports all of them")
+	out := fs.String("o", "", "sqlite and jsonl: write to this file instead of standard output")
+	layout := fs.String("layout", string(aio.LayoutLlamaIndex), "jsonl: the line layout, llamaindex or langchain")
+	table := fs.String("table", aio.DefaultExportTable, "sqlite: the table of the chunks; full-text search uses <table>_fts")
+	endpoint := fs.String("endpoint", "", "http: the bulk upsert URL records are POSTed to")
+	endpointToken := fs.String("endpoint-token", "", "http: bearer token sent to -endpoint")
+	batch := fs.Int("batch", aio.DefaultExportBatchSize, "http: records per request")
+	embedModel := fs.String("embed-model", "", "embed each chunk with this model, e.g. text-embedding-3-small; empty exports no embeddings")
+	embedURL := fs.String("embed-url", aio.DefaultEmbeddingsURL, "OpenAI-compatible embeddings endpoint for -embed-model")
+	embedKey := fs.String("embed-key", "", "API key for -embed-url")
+	opts := aio.LoadConfigFromEnv()
+	transportFlags(fs, &opts)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio export -sink sqlite|jsonl|http [flags] <url>")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	var buf bytes.Buffer
+	var exp aio.Exporter
+	switch *sink {
+	case "sqlite":
+		exp = &aio.SQLiteExporter{W: &buf, Table: *table}
+	case "jsonl":
+		exp = &aio.JSONLExporter{W: &buf, Layout: aio.JSONLLayout(*layout)}
+	case "http":
+		if *endpoint == "" {
+			fmt.Fprintln(os.Stderr, "Error: -sink http needs an -endpoint")
+			return exitUsage
+		}
+		exp = &aio.HTTPExporter{URL: *endpoint, Token: *endpointToken, BatchSize: *batch}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown sink %q (want sqlite, jsonl or http)\n", *sink)
+		return exitUsage
+	}
+	var embedder aio.Embedder
+	if *embedModel != "" {
+		embedder = &aio.HTTPEmbedder{URL: *embedURL, Model: *embedModel, APIKey: *embedKey}
+	}
+
+	opts.ItemsOnly = true
+	opts.InlineKeywords = true
+	env, err := aio.ParseWithOptions(args[0], *query, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return fetchExitCode(err)
+	}
+	if *query != "" && len(env.Items) == 0 {
+		fmt.Fprintf(os.Stderr, "aio: no content matched %q\n", *query)
+		return exitNoMatch
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := aio.ExportEnvelope(ctx, env, exp, embedder); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	// The file is written only once the export is whole
+	switch {
+	case *sink == "http":
+		fmt.Fprintf(os.Stderr, "Exported %d chunks to %s\n", len(env.Items), *endpoint)
+	case *out == "":
+		os.Stdout.Write(buf.Bytes())
+	default:
+		if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d chunks to %s\n", len(env.Items), *out)
+	}
+	return exitOK
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"aio-parser-go/aio"
+)
+
+// runFetch implements "aio fetch [flags] <url>". It exits 0 with the
+// content printed, 3 when a query matched none of it, 4 when the document
+// was read but rejected as malformed, unsupported, oversized or failing
+// verification, 5 when the server asked for credentials, 1 when it could
+// not be fetched at all and 2 on bad usage.
+func runFetch(args []string) int {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	query := fs.String("query", "", "keywords for targeted retrieval; empty returns all content")
+	maxTokens := fs.Int("max-tokens", 0, "cap on the estimated tokens of the content, 0 for no limit")
+	format := fs.String("format", "", "print the matched chunks as md, text or jsonl (one chunk per line) instead of the narrative")
+	asJSON := fs.Bool("json", false, "print the content envelope as JSON")
+	noFallback := fs.Bool("no-fallback", false, "fail rather than scrape the page when the site publishes no AIO document")
+	sanitize := fs.Bool("sanitize", false, "strip scripts, HTML and prompt-injection phrasing from the content")
+	token := fs.String("token", "", "bearer token sent to the site's host only")
+	summarize := fs.Bool("summarize", false, "with -max-tokens, shorten chunks to their best sentences rather than dropping whole ones")
+	languages := fs.String("lang", "", "comma-separated languages to keep chunks in, e.g. de,en")
+	stemming := fs.Bool("stem", false, "match other inflections of the query's words, in each chunk's language")
+	changedOnly := fs.Bool("changed-only", false, "print only the chunks added or changed since the last fetch, tracked under -cache-dir")
+	opts := aio.LoadConfigFromEnv()
+	transportFlags(fs, &opts)
+	fs.StringVar(&opts.CacheDir, "cache-dir", opts.CacheDir, "directory caching documents and, with -changed-only, the chunks last fetched")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio fetch [flags] <url>")
+		fs.PrintDefaults()
+	}
+	rest := parseArgs(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	opts.MaxTokens = *maxTokens
+	opts.TrackChanges, opts.ChangedOnly = *changedOnly, *changedOnly
+	opts.Stemming = *stemming
+	if *token != "" {
+		if u, err := url.Parse(rest[0]); err == nil && u.Host != "" {
+			opts.Credentials = map[string]aio.Credential{u.Host: {BearerToken: *token}}
+		}
+	}
+	if *summarize {
+		opts.Summarizer = aio.ExtractiveSummarizer{}
+	}
+	if *languages != "" {
+		opts.Languages = strings.Split(*languages, ",")
+	}
+	if *sanitize {
+		opts.Sanitizer = &aio.ContentSanitizer{}
+	}
+	if *noFallback {
+		opts.FallbackChain = nil
+		for _, s := range aio.DefaultFallbackChain {
+			if s != aio.StrategyScrape {
+				opts.FallbackChain = append(opts.FallbackChain, s)
+			}
+		}
+	}
+	return fetch(rest[0], *query, opts, *format, *asJSON)
+}
+
+// fetch parses url for query and prints the result: the JSON envelope
+// when asJSON, the chunks in format when one is named, and the narrative
+// otherwise. It returns the exit code runFetch documents.
+func fetch(url, query string, opts aio.Options, format string, asJSON bool) int {
+	var f aio.Format
+	if format != "" {
+		var err error
+		if f, err = aio.ParseFormat(format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+		opts.ItemsOnly = true
+	}
+
+	result, err := aio.ParseWithOptions(url, query, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return fetchExitCode(err)
+	}
+
+	switch {
+	case asJSON:
+		printJSON(result)
+	case f != "":
+		out, err := result.Render(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(out)
+	default:
+		if result.Narrative != "" {
+			fmt.Println(strings.TrimRight(result.Narrative, "\n"))
+		}
+	}
+
+	if query != "" && len(result.Items) == 0 {
+		fmt.Fprintf(os.Stderr, "aio: no content matched %q\n", query)
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// fetchExitCode tells a document that was read and rejected, or refused
+// for want of credentials, from one that could not be fetched
+func fetchExitCode(err error) int {
+	if errors.Is(err, aio.ErrUnauthorized) {
+		return exitAuth
+	}
+	for _, rejected := range []error{
+		aio.ErrMalformed,
+		aio.ErrUnsupportedVersion,
+		aio.ErrInvalidSignature,
+		aio.ErrHashMismatch,
+		aio.ErrTooLarge,
+	} {
+		if errors.Is(err, rejected) {
+			return exitInvalid
+		}
+	}
+	return exitFailure
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aio-parser-go/aio"
+)
+
+// runGenerate implements "aio generate [-o file] [-keywords n] [-cbor] <dir>".
+// It exits 0 once the document is written, 1 when it could not be built
+// or written and 2 on a usage error.
+func runGenerate(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	out := fs.String("o", "", "write the document to this file instead of standard output, e.g. site/ai-content.aio")
+	keywords := fs.Int("keywords", aio.DefaultMaxKeywords, "maximum keywords per index entry")
+	cbor := fs.Bool("cbor", false, "write the document as CBOR, to be served as "+aio.CBORContentType)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio generate [-o file] [-keywords n] [-cbor] <dir>")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	doc, err := aio.Generate(args[0], aio.GenerateOptions{MaxKeywords: *keywords})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	encode := aio.Encode
+	if *cbor {
+		encode = aio.EncodeCBOR
+	}
+	data, err := encode(doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	if *out == "" {
+		os.Stdout.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d chunks to %s\n", len(doc.Content), *out)
+	return exitOK
+}
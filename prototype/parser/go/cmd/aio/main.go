@@ -0,0 +1,228 @@
+// Command aio reads and publishes AIO content.
+//
+//	aio fetch [flags] <url>
+//
+// prints the content a site publishes for a query, as its narrative, as
+// Markdown, text or JSONL (-format) or as the JSON envelope (-json);
+//
+//	aio validate [-json] <file|dir|url>
+//
+// checks a document for errors and warnings;
+//
+//	aio generate [-o file] <dir>
+//
+// builds an ai-content.aio from a directory of HTML and Markdown pages;
+//
+//	aio serve [-addr addr] <file|dir>
+//
+// publishes one, answering ?q= and ?ids= with the matching chunks;
+//
+//	aio export -sink sqlite|jsonl|http [flags] <url>
+//
+// writes a site's chunks, with optional embeddings, to a RAG pipeline's
+// store;
+//
+//	aio mcp [-sse addr]
+//
+// runs a Model Context Protocol server whose tools fetch AIO content; and
+//
+//	aio conformance [-update] <dir>
+//
+// checks a corpus of .aio fixtures, such as a generator's output, against
+// the parses and golden envelopes they expect.
+// Flags may come before or after the arguments. Without a subcommand,
+// the flags -serve, -schema, -capabilities and -discover are accepted as
+// before, and -url parses like fetch.
+//
+// Every subcommand exits with one of the codes below, so scripts and CI
+// can tell a failed fetch from a query that matched nothing.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"aio-parser-go/aio"
+)
+
+// Exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1 // the operation failed, or validate found errors
+	exitUsage   = 2 // bad flags or arguments, or an input that could not be read
+	exitNoMatch = 3 // fetch: the query matched no content
+	exitInvalid = 4 // fetch: the document is malformed, unsupported or fails verification
+	exitAuth    = 5 // fetch: the server refused the request for want of credentials
+)
+
+const usage = `usage: aio <command> [flags] [args]
+
+commands:
+  fetch <url>               print the content a site publishes for -query
+  validate <file|dir|url>   check a document for errors and warnings
+  generate <dir>            build an ai-content.aio from HTML and Markdown pages
+  serve <file|dir>          publish a document over HTTP
+  export <url>              write a site's chunks to SQLite, JSONL or an HTTP endpoint
+  mcp                       run a Model Context Protocol server
+  conformance <dir>         check a corpus of fixtures against expected parses
+
+Run "aio <command> -h" for a command's flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "fetch":
+		os.Exit(runFetch(args))
+	case "validate":
+		os.Exit(runValidate(args))
+	case "generate":
+		os.Exit(runGenerate(args))
+	case "serve":
+		os.Exit(runServe(args))
+	case "mcp":
+		os.Exit(runMCP(args))
+	case "export":
+		os.Exit(runExport(args))
+	case "conformance":
+		os.Exit(runConformance(args))
+	case "help":
+		fmt.Print(usage)
+		os.Exit(exitOK)
+	default:
+		if !strings.HasPrefix(cmd, "-") {
+			fmt.Fprintf(os.Stderr, "aio: unknown command %q\n\n%s", cmd, usage)
+			os.Exit(exitUsage)
+		}
+	}
+	os.Exit(runFlags(os.Args[1:]))
+}
+
+// runFlags is the command line from before subcommands: one of -serve,
+// -schema, -capabilities or -discover, or a parse of -url
+func runFlags(args []string) int {
+	fs := flag.NewFlagSet("aio", flag.ExitOnError)
+	url := fs.String("url", "", "site to fetch AIO content from")
+	query := fs.String("query", "", "keywords for targeted retrieval")
+	asJSON := fs.Bool("json", false, "print the content envelope as JSON")
+	itemsOnly := fs.Bool("items-only", false, "return matched chunks without building a narrative")
+	format := fs.String("format", "", "print the matched chunks as md, text or jsonl (one chunk per line)")
+	schema := fs.Bool("schema", false, "print the JSON Schema of the AIO document format and exit")
+	capabilities := fs.Bool("capabilities", false, "print the parser's supported versions, formats and features as JSON and exit")
+	discover := fs.Bool("discover", false, "print where -url publishes its AIO document and how it was found, as JSON, and exit")
+	serve := fs.String("serve", "", "serve parses over HTTP on this address, e.g. :8080, instead of parsing -url")
+	logLevel := fs.String("log", "", "with -serve, log parses and fetches to stderr as JSON at this level: debug, info, warn or error")
+	opts := aio.LoadConfigFromEnv()
+	transportFlags(fs, &opts)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fmt.Fprintln(fs.Output(), "\nflags without a command:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *schema {
+		os.Stdout.Write(aio.Schema())
+		fmt.Println()
+		return exitOK
+	}
+	if *capabilities {
+		printJSON(aio.Capabilities())
+		return exitOK
+	}
+
+	if *serve != "" {
+		// SIGINT and SIGTERM drain in-flight parses before exiting, as
+		// orchestrators expect when they stop or replace the process
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		p := &aio.Parser{Options: opts, Metrics: &aio.PrometheusMetrics{}}
+		if *logLevel != "" {
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: -log: %v\n", err)
+				return exitUsage
+			}
+			p.StructuredLogger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		}
+		fmt.Printf("Serving /parse, /parse/stream and /metrics on %s\n", *serve)
+		if err := aio.Serve(ctx, *serve, p); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	if *url == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	if *discover {
+		found, err := (&aio.Parser{Options: opts}).Discover(context.Background(), *url)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		printJSON(found)
+		return exitOK
+	}
+	opts.ItemsOnly = *itemsOnly
+	return fetch(*url, *query, opts, *format, *asJSON)
+}
+
+// transportFlags registers the flags that set how documents are fetched
+// and how large they may be, defaulting to opts, which the environment
+// has filled in: an explicit flag wins over AIO_* variables, which win
+// over the built-in defaults
+func transportFlags(fs *flag.FlagSet, opts *aio.Options) {
+	// The URL on the command line is the user's own; for -serve, whose
+	// URLs come from clients, NewHandler drops this
+	opts.TrustParseHost = true
+	fs.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "per-request timeout, 0 for the default of 10s, negative for none (env AIO_TIMEOUT)")
+	fs.IntVar(&opts.Retries, "retries", opts.Retries, "retries of requests failing with a network error or 5xx status")
+	fs.IntVar(&opts.MaxRedirects, "max-redirects", opts.MaxRedirects, "redirects to follow per request, 0 for the default of 5, negative for none")
+	fs.StringVar(&opts.UserAgent, "user-agent", opts.UserAgent, "User-Agent header (env AIO_USER_AGENT)")
+	fs.Int64Var(&opts.MaxBytes, "max-bytes", opts.MaxBytes, "maximum document size in bytes (env AIO_MAX_BYTES)")
+	fs.IntVar(&opts.MaxChunks, "max-chunks", opts.MaxChunks, "maximum content chunks in a document, 0 for no limit")
+	fs.IntVar(&opts.MaxChunkBytes, "max-chunk-bytes", opts.MaxChunkBytes, "maximum content bytes in a single chunk, 0 for no limit")
+	fs.BoolVar(&opts.IgnoreRobots, "ignore-robots", opts.IgnoreRobots, "fetch even what the site's robots.txt disallows")
+	fs.BoolVar(&opts.AllowPrivateNetworks, "allow-private", opts.AllowPrivateNetworks, "follow links to loopback and private addresses found in responses")
+	fs.IntVar(&opts.Concurrency, "concurrency", opts.Concurrency, "parallel fetches for batch operations (env AIO_CONCURRENCY)")
+}
+
+// parseArgs parses flags wherever they appear among args, so that
+// "aio fetch <url> -query q" works as well as "aio fetch -query q <url>",
+// and returns the remaining arguments. Arguments after "--" are taken as
+// they are.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		remaining := fs.Args()
+		if len(remaining) == 0 {
+			return rest
+		}
+		// Parse stops at the first non-flag, or consumes a "--" first
+		if parsed := len(args) - len(remaining); parsed > 0 && args[parsed-1] == "--" {
+			return append(rest, remaining...)
+		}
+		rest = append(rest, remaining[0])
+		args = remaining[1:]
+	}
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
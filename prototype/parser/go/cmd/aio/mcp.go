@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"aio-parser-go/aio"
+)
+
+// runMCP implements "aio mcp [-sse addr]", a Model Context Protocol
+// server exposing fetch_aio and discover_aio. It speaks over standard
+// input and output, as MCP clients run local servers, or with -sse over
+// HTTP. Parses use the AIO_* environment configuration. It exits 1 when
+// the server fails and 2 on a usage error.
+func runMCP(args []string) int {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	sse := fs.String("sse", "", "serve over HTTP with Server-Sent Events on this address, e.g. :8081, instead of stdio")
+	opts := aio.LoadConfigFromEnv()
+	fs.BoolVar(&opts.IgnoreRobots, "ignore-robots", opts.IgnoreRobots, "fetch even what a site's robots.txt disallows")
+	fs.BoolVar(&opts.AllowPrivateNetworks, "allow-private", opts.AllowPrivateNetworks, "let tools reach loopback and private addresses")
+	sanitize := fs.Bool("sanitize", true, "strip scripts, HTML and prompt-injection phrasing from the content tools return")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio mcp [-sse addr]")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if *sanitize {
+		opts.Sanitizer = &aio.ContentSanitizer{}
+	}
+	server := aio.NewMCPServer(&aio.Parser{Options: opts})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var err error
+	if *sse != "" {
+		// Standard output is the protocol channel only in stdio mode
+		fmt.Printf("Serving MCP on %s (GET /sse, POST /message)\n", *sse)
+		err = aio.ServeHandler(ctx, *sse, server.Handler())
+	} else {
+		err = server.ServeStdio(ctx, os.Stdin, os.Stdout)
+	}
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"aio-parser-go/aio"
+)
+
+// linkHeader advertises the document on every page of a served site, as
+// the spec asks of publishers
+const linkHeader = `</ai-content.aio>; rel="alternate"; type="` + aio.ContentType + `"`
+
+// runServe implements "aio serve [-addr addr] <file|dir>". A file is
+// served at /ai-content.aio; a directory's other files are served
+// alongside its ai-content.aio, with the Link header that lets agents
+// discover it. It exits 1 when the document cannot be read or the server
+// fails, and 2 on a usage error.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8000", "address to listen on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio serve [-addr addr] <file|dir>")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	target, site := args[0], ""
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		site, target = target, filepath.Join(target, "ai-content.aio")
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	doc, err := aio.NewDocumentHandler(data, info.ModTime())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", target, err)
+		return exitFailure
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/ai-content.aio", doc)
+	if site != "" {
+		files := http.FileServer(http.Dir(site))
+		mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p := r.URL.Path; strings.HasSuffix(p, "/") || strings.HasSuffix(p, ".html") || strings.HasSuffix(p, ".htm") {
+				w.Header().Set("Link", linkHeader)
+			}
+			files.ServeHTTP(w, r)
+		}))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Serving %s as /ai-content.aio on %s\n", target, *addr)
+	if err := aio.ServeHandler(ctx, *addr, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
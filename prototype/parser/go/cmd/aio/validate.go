@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aio-parser-go/aio"
+)
+
+// runValidate implements "aio validate [-json] <file|dir|url>". It exits 0
+// for a valid document, warnings or not, 1 when it has errors and 2 when
+// it could not be read.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the issues as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aio validate [-json] <file|dir|url>")
+		fs.PrintDefaults()
+	}
+	args = parseArgs(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	data, err := readDocument(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	issues := aio.ValidateDocument(data)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if issues == nil {
+			issues = []aio.Issue{}
+		}
+		enc.Encode(issues)
+	} else {
+		for _, i := range issues {
+			fmt.Println(i)
+		}
+		if len(issues) == 0 {
+			fmt.Println("ok")
+		}
+	}
+	if aio.HasErrors(issues) {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// readDocument reads an .aio document from a file, the ai-content.aio of
+// a directory, or the document a site URL publishes
+func readDocument(target string) ([]byte, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		opts := aio.LoadConfigFromEnv()
+		opts.TrustParseHost = true
+		found, err := (&aio.Parser{Options: opts}).Discover(context.Background(), target)
+		if err != nil {
+			return nil, err
+		}
+		body, _, err := aio.FetchWithOptions(found.URL, opts)
+		return body, err
+	}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		target = filepath.Join(target, "ai-content.aio")
+	}
+	return os.ReadFile(target)
+}
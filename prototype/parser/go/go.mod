@@ -1,3 +1,10 @@
-module aio-parser-go
-
-go 1.21
+module aio-parser-go
+
+go 1.21
+
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.33.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect